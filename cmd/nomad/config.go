@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/psidex/nomad/internal/graphs"
+	"github.com/psidex/nomad/internal/graphs/assets"
+	"github.com/psidex/nomad/internal/graphs/dot"
+	"github.com/psidex/nomad/internal/lib"
+	"github.com/psidex/nomad/internal/nomad"
+)
+
+// cliConfig is everything that configures a CLI crawl, it can be loaded from a JSON or
+// YAML file and overridden by flags.
+type cliConfig struct {
+	nomad.Config
+	Runtime           lib.Duration `json:"runtime"`
+	HttpClientTimeout lib.Duration `json:"httpClientTimeout"`
+	Outputs           []string     `json:"outputs"`
+	Filename          string       `json:"filename"`
+	SnapshotInterval  lib.Duration `json:"snapshotInterval"`
+	SQLite            string       `json:"sqlite"`
+	Postgres          string       `json:"postgres"`
+	PostgresSession   string       `json:"postgresSession"`
+	Offline           bool         `json:"offline"`
+	CollapseDirection bool         `json:"collapseDirection"`
+	Dot               dot.Options  `json:"dot"`
+}
+
+func defaultConfig() cliConfig {
+	return cliConfig{
+		Config: nomad.Config{
+			WorkerCount:        3,
+			InitialUrls:        []string{"https://www.france.fr/"},
+			RespectRobotsTxt:   true,
+			HostDelay:          lib.DurationFrom(time.Millisecond * 5000),
+			IPDelay:            lib.DurationFrom(time.Millisecond * 1000),
+			MaxRetries:         1,
+			CheckpointInterval: lib.DurationFrom(time.Minute),
+			Frontier:           "memory",
+			VisitedSet:         "exact",
+			LinkSources:        []graphs.LinkKind{graphs.LinkAnchor},
+		},
+		Runtime:           lib.DurationFrom(time.Second * 15),
+		HttpClientTimeout: lib.DurationFrom(time.Second * 10),
+		Outputs:           []string{"vis"},
+		Filename:          "nomaddata",
+		CollapseDirection: true,
+		Dot:               dot.Options{Directed: true},
+	}
+}
+
+// validate checks the CLI specific options as well as the nomad.Config.
+func (c cliConfig) validate() error {
+	if err := c.Config.Validate(); err != nil {
+		return err
+	}
+	if c.Runtime.Duration < 0 {
+		return errors.New("runtime can't be negative")
+	}
+	if c.HttpClientTimeout.Duration < 0 {
+		return errors.New("httpClientTimeout can't be negative")
+	}
+	if c.Filename == "" {
+		return errors.New("filename can't be empty")
+	}
+	if c.Offline {
+		// Fail now rather than after the crawl.
+		if err := assets.Check(assets.VisNetwork, assets.ECharts); err != nil {
+			return err
+		}
+	}
+	if err := c.Dot.Validate(); err != nil {
+		return err
+	}
+	if c.SnapshotInterval.Duration < 0 {
+		return errors.New("snapshotInterval can't be negative")
+	}
+	outputs, err := c.outputs()
+	if err != nil {
+		return err
+	}
+	for _, output := range outputs {
+		if c.SnapshotInterval.Duration > 0 && output.Filename == graphs.StdoutFilename {
+			return errors.New("snapshots can't be written to stdout")
+		}
+	}
+	return nil
+}
+
+// outputs creates a graphs.Output for every "provider[=filename]" in Outputs. Outputs
+// without a filename use Filename, suffixed with the provider's name if there's more
+// than one output. A filename of "-" writes to stdout.
+func (c cliConfig) outputs() ([]graphs.Output, error) {
+	if len(c.Outputs) == 0 {
+		return nil, errors.New("at least one output is required")
+	}
+
+	outputs := make([]graphs.Output, 0, len(c.Outputs))
+	rendered := make(map[string]string)
+
+	for _, output := range c.Outputs {
+		name, filename, _ := strings.Cut(output, "=")
+		provider, err := c.newGraphProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		if filename == "" {
+			filename = c.Filename
+			if len(c.Outputs) > 1 {
+				filename += "-" + name
+			}
+		}
+
+		path := filename
+		if filename != graphs.StdoutFilename {
+			path = filepath.Clean(filename + provider.Extension())
+		}
+		if other, ok := rendered[path]; ok {
+			return nil, fmt.Errorf("outputs %s and %s would both write to %s", other, output, path)
+		}
+		rendered[path] = output
+
+		outputs = append(outputs, graphs.Output{Provider: provider, Filename: filename})
+	}
+
+	return outputs, nil
+}
+
+// loadConfigFile reads a JSON or YAML (chosen by extension) config file over the top of
+// cfg, rejecting any unknown keys.
+func loadConfigFile(path string, cfg *cliConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		// This converts to JSON so the json tags and unmarshallers are used for both.
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return err
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("could not load config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeConfig writes cfg as indented JSON, which loadConfigFile can read back.
+func writeConfig(w io.Writer, cfg cliConfig) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(cfg)
+}
+
+// stringsFlag is a flag.Value that can be given multiple times. The first time it's set
+// any existing values (e.g. from a config file) are replaced.
+type stringsFlag struct {
+	values *[]string
+	set    bool
+}
+
+func (s *stringsFlag) String() string {
+	if s.values == nil {
+		return ""
+	}
+	return strings.Join(*s.values, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	if !s.set {
+		*s.values = nil
+		s.set = true
+	}
+	*s.values = append(*s.values, value)
+	return nil
+}
+
+// linkSourcesFlag is a stringsFlag for link sources, which are checked as they're set.
+type linkSourcesFlag struct {
+	values *[]graphs.LinkKind
+	set    bool
+}
+
+func (l *linkSourcesFlag) String() string {
+	if l.values == nil {
+		return ""
+	}
+	sources := make([]string, len(*l.values))
+	for i, kind := range *l.values {
+		sources[i] = string(kind)
+	}
+	return strings.Join(sources, ",")
+}
+
+func (l *linkSourcesFlag) Set(value string) error {
+	kind, err := graphs.ParseLinkKind(value)
+	if err != nil {
+		return err
+	}
+	if !l.set {
+		*l.values = nil
+		l.set = true
+	}
+	*l.values = append(*l.values, kind)
+	return nil
+}
+
+// newFlagSet creates the CLI flags, which write directly in to cfg.
+func newFlagSet(cfg *cliConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("nomad", flag.ContinueOnError)
+
+	// These are handled separately, but have to be defined so parsing doesn't fail.
+	fs.String("config", "", "a JSON or YAML config file, flags override its values")
+	fs.Bool("print-config", false, "print the config that would be used as JSON and exit")
+
+	fs.Var(&stringsFlag{values: &cfg.InitialUrls}, "url", "an initial URL to crawl, can be given multiple times")
+	fs.Var(&cfg.Runtime, "runtime", "how long to crawl for, 0 runs until the frontier is exhausted")
+	fs.UintVar(&cfg.WorkerCount, "workers", cfg.WorkerCount, "the number of concurrent workers")
+	fs.Var(&cfg.WorkerCooldown, "worker-cooldown", "how long each worker sleeps after every URL")
+	fs.Var(&cfg.HttpClientTimeout, "http-timeout", "the timeout for each HTTP request")
+	fs.BoolVar(&cfg.RandomCrawl, "random", cfg.RandomCrawl, "pop URLs from the frontier randomly instead of FIFO")
+	fs.BoolVar(&cfg.DiscoveryTree, "discovery-tree", cfg.DiscoveryTree, "only record the connection that first discovered each hostname")
+	fs.Var(&linkSourcesFlag{values: &cfg.LinkSources}, "link-source", "where in each page to find URLs, can be given multiple times, sources are a, link, script, img, iframe, form, meta-refresh, meta, and tls-san")
+	fs.BoolVar(&cfg.EnqueueCertificateNames, "enqueue-certificate-names", cfg.EnqueueCertificateNames, "crawl the hostnames found by the tls-san link source instead of only recording them")
+	fs.Var(&stringsFlag{values: &cfg.Outputs}, "output", "a graph to output as provider[=filename], can be given multiple times, providers are echarts, vis, json, graphology, dot, gexf, and graphml")
+	fs.StringVar(&cfg.Filename, "filename", cfg.Filename, "the default output file name, without an extension")
+
+	fs.StringVar(&cfg.SQLite, "sqlite", cfg.SQLite, "also write every hostname, connection, and fetch result to this SQLite database")
+	fs.StringVar(&cfg.Postgres, "postgres", cfg.Postgres, "also write every hostname, connection, and fetch result to this PostgreSQL database (a connection string)")
+	fs.StringVar(&cfg.PostgresSession, "postgres-session", cfg.PostgresSession, "the Postgres session to write to, an existing session is resumed, a new one is created if empty")
+	fs.Var(&cfg.SnapshotInterval, "snapshot-interval", "render every output this often during the crawl, 0 only renders at the end")
+	fs.BoolVar(&cfg.CollapseDirection, "collapse-direction", cfg.CollapseDirection, "count links in either direction between two hostnames as one edge in the graphology and echarts outputs")
+	fs.BoolVar(&cfg.Offline, "offline", cfg.Offline, "embed the JavaScript in HTML outputs so they open without network access")
+	fs.BoolVar(&cfg.Dot.Directed, "dot-directed", cfg.Dot.Directed, "output a directed DOT graph")
+	fs.StringVar((*string)(&cfg.Dot.Cluster), "dot-cluster", string(cfg.Dot.Cluster), "group DOT nodes in to subgraphs by: domain or tld")
+	fs.BoolVar(&cfg.Dot.Degree, "dot-degree", cfg.Dot.Degree, "add indegree and outdegree attributes to DOT nodes")
+	fs.BoolVar(&cfg.Dot.DeadEnds, "dot-dead-ends", cfg.Dot.DeadEnds, "highlight DOT nodes that were crawled but linked nowhere")
+
+	fs.BoolVar(&cfg.RespectRobotsTxt, "robots", cfg.RespectRobotsTxt, "respect robots.txt")
+	fs.Var(&cfg.HostDelay, "host-delay", "the minimum time between requests to the same hostname")
+	fs.Var(&cfg.IPDelay, "ip-delay", "the minimum time between requests to the same IP address")
+	fs.UintVar(&cfg.MaxRetries, "max-retries", cfg.MaxRetries, "how many times to retry a host that responds with 429 or 503")
+	fs.UintVar(&cfg.MaxRedirects, "max-redirects", cfg.MaxRedirects, "how many redirects to follow when fetching a host, 0 uses the default of 10")
+
+	fs.UintVar(&cfg.MaxHostsFetched, "max-hosts-fetched", cfg.MaxHostsFetched, "stop after fetching this many hosts, 0 is unlimited")
+	fs.UintVar(&cfg.MaxHostnames, "max-hostnames", cfg.MaxHostnames, "stop after discovering this many hostnames, 0 is unlimited")
+	fs.UintVar(&cfg.MaxDepth, "max-depth", cfg.MaxDepth, "don't crawl hosts more than this many hops from the initial URLs, 0 is unlimited")
+
+	fs.StringVar(&cfg.CheckpointFile, "checkpoint", cfg.CheckpointFile, "save checkpoints to this file, gzipped if it ends with .gz")
+	fs.Var(&cfg.CheckpointInterval, "checkpoint-interval", "how often to save a checkpoint")
+	fs.BoolVar(&cfg.Resume, "resume", cfg.Resume, "resume from the checkpoint file if it exists")
+
+	fs.StringVar(&cfg.Frontier, "frontier", cfg.Frontier, "the frontier to use: memory or disk")
+	fs.StringVar(&cfg.FrontierPath, "frontier-path", cfg.FrontierPath, "the database file for the disk frontier, a temporary file is used if empty")
+	fs.IntVar(&cfg.FrontierMemoryLimit, "frontier-memory-limit", cfg.FrontierMemoryLimit, "how many queued URLs the disk frontier keeps in memory, 0 uses the default")
+	fs.StringVar(&cfg.VisitedSet, "visited-set", cfg.VisitedSet, "the visited set to use: exact or bloom")
+	fs.UintVar(&cfg.BloomCapacity, "bloom-capacity", cfg.BloomCapacity, "how many URLs the bloom visited set is sized for, 0 uses the default")
+	fs.Float64Var(&cfg.BloomFalsePositiveRate, "bloom-error-rate", cfg.BloomFalsePositiveRate, "the false positive rate the bloom visited set is sized for, 0 uses the default")
+
+	return fs
+}
+
+// parseConfig builds the config from the defaults, then the config file if one is
+// given, then the flags.
+func parseConfig(args []string) (cfg cliConfig, printConfig bool, err error) {
+	// Parse once just to find the config file, the flags are written to a throwaway
+	// config.
+	scratch := defaultConfig()
+	fs := newFlagSet(&scratch)
+	fs.SetOutput(&bytes.Buffer{})
+	if err := fs.Parse(args); err != nil {
+		// Parse again with output so the usage or error is shown.
+		fs = newFlagSet(&scratch)
+		return cfg, false, fs.Parse(args)
+	}
+	configPath := fs.Lookup("config").Value.String()
+	printConfig = fs.Lookup("print-config").Value.String() == "true"
+
+	cfg = defaultConfig()
+	if configPath != "" {
+		if err := loadConfigFile(configPath, &cfg); err != nil {
+			return cfg, false, err
+		}
+	}
+
+	// Now the flags can override the file.
+	if err := newFlagSet(&cfg).Parse(args); err != nil {
+		return cfg, false, err
+	}
+
+	return cfg, printConfig, cfg.validate()
+}
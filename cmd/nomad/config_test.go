@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/psidex/nomad/internal/graphs"
+	"github.com/psidex/nomad/internal/graphs/dot"
+)
+
+// writeFile writes contents to name in a temporary directory and returns its path.
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseConfigDefaults(t *testing.T) {
+	cfg, printConfig, err := parseConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if printConfig {
+		t.Error("printConfig is set without --print-config")
+	}
+	if !reflect.DeepEqual(cfg, defaultConfig()) {
+		t.Errorf("got %+v, expected the defaults", cfg)
+	}
+}
+
+func TestParseConfigFile(t *testing.T) {
+	for _, test := range []struct {
+		name, contents string
+	}{
+		{"config.json", `{
+			"workerCount": 7,
+			"initialUrls": ["https://a.example/", "https://b.example/"],
+			"runtime": "2m",
+			"linkSources": ["a", "img"],
+			"outputs": ["dot", "gexf=graph"],
+			"dot": {"cluster": "domain"}
+		}`},
+		{"config.yaml", `
+workerCount: 7
+initialUrls:
+  - https://a.example/
+  - https://b.example/
+runtime: 2m
+linkSources: [a, img]
+outputs: [dot, gexf=graph]
+dot:
+  cluster: domain
+`},
+		{"config.YML", `{workerCount: 7, initialUrls: [https://a.example/, https://b.example/], runtime: 2m, linkSources: [a, img], outputs: [dot, gexf=graph], dot: {cluster: domain}}`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeFile(t, test.name, test.contents)
+			cfg, _, err := parseConfig([]string{"--config", path})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := defaultConfig()
+			expected.WorkerCount = 7
+			expected.InitialUrls = []string{"https://a.example/", "https://b.example/"}
+			expected.Runtime.Duration = 2 * time.Minute
+			expected.LinkSources = []graphs.LinkKind{graphs.LinkAnchor, graphs.LinkImage}
+			expected.Outputs = []string{"dot", "gexf=graph"}
+			// Nested objects replace only the fields they set.
+			expected.Dot = dot.Options{Directed: true, Cluster: dot.ClusterDomain}
+			if !reflect.DeepEqual(cfg, expected) {
+				t.Errorf("got %+v, expected %+v", cfg, expected)
+			}
+		})
+	}
+}
+
+func TestParseConfigFileErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, contents, expected string
+	}{
+		{"unknown.json", `{"workerCount": 1, "wokerCount": 2}`, `unknown field "wokerCount"`},
+		{"unknown.yaml", "dot:\n  clusters: domain\n", `unknown field "clusters"`},
+		{"type.json", `{"workerCount": "many"}`, "workerCount"},
+		{"syntax.json", `{"workerCount": 1`, "unexpected EOF"},
+		{"invalid.json", `{"workerCount": 0}`, "worker"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeFile(t, test.name, test.contents)
+			_, _, err := parseConfig([]string{"--config", path})
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("got error %v, expected one containing %q", err, test.expected)
+			}
+		})
+	}
+
+	if _, _, err := parseConfig([]string{"--config", filepath.Join(t.TempDir(), "missing.json")}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v for a missing file, expected it not to exist", err)
+	}
+}
+
+func TestParseConfigFlagsOverrideFile(t *testing.T) {
+	path := writeFile(t, "config.json", `{
+		"workerCount": 7,
+		"initialUrls": ["https://a.example/", "https://b.example/"],
+		"outputs": ["dot"],
+		"linkSources": ["a", "img"],
+		"respectRobotsTxt": false,
+		"maxDepth": 3
+	}`)
+
+	// The flags can come before or after --config.
+	cfg, _, err := parseConfig([]string{
+		"--workers", "2",
+		"--config", path,
+		"--url", "https://c.example/",
+		"--output", "gexf", "--output", "graphml",
+		"--robots",
+		"--host-delay", "1s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.WorkerCount != 2 || !cfg.RespectRobotsTxt || cfg.HostDelay.Duration != time.Second {
+		t.Errorf("got workers %d, robots %t and host delay %s, expected the flags", cfg.WorkerCount, cfg.RespectRobotsTxt, cfg.HostDelay.Duration)
+	}
+	// Repeated flags replace the file's list rather than adding to it.
+	if !reflect.DeepEqual(cfg.InitialUrls, []string{"https://c.example/"}) {
+		t.Errorf("got URLs %q, expected only the flag's", cfg.InitialUrls)
+	}
+	if !reflect.DeepEqual(cfg.Outputs, []string{"gexf", "graphml"}) {
+		t.Errorf("got outputs %q, expected the flags'", cfg.Outputs)
+	}
+	// Anything without a flag keeps the file's value.
+	if cfg.MaxDepth != 3 || !reflect.DeepEqual(cfg.LinkSources, []graphs.LinkKind{graphs.LinkAnchor, graphs.LinkImage}) {
+		t.Errorf("got max depth %d and link sources %q, expected the file's", cfg.MaxDepth, cfg.LinkSources)
+	}
+}
+
+func TestParseConfigFlagErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"--no-such-flag"},
+		{"--link-source", "blink"},
+		{"--workers", "-1"},
+		{"--runtime", "soon"},
+	} {
+		if _, _, err := parseConfig(args); err == nil {
+			t.Errorf("%q didn't return an error", args)
+		}
+	}
+	if _, _, err := parseConfig([]string{"--help"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("--help returned %v, expected flag.ErrHelp", err)
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	cfg, printConfig, err := parseConfig([]string{"--print-config", "--workers", "5", "--output", "dot", "--dot-cluster", "tld"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !printConfig {
+		t.Fatal("printConfig isn't set by --print-config")
+	}
+
+	// The printed config can be used as a config file and gives the same config.
+	var buf bytes.Buffer
+	if err := writeConfig(&buf, cfg); err != nil {
+		t.Fatal(err)
+	}
+	path := writeFile(t, "printed.json", buf.String())
+	loaded, printConfig, err := parseConfig([]string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if printConfig {
+		t.Error("printConfig is set without --print-config")
+	}
+	if !reflect.DeepEqual(loaded, cfg) {
+		t.Errorf("loading the printed config gave %+v, expected %+v", loaded, cfg)
+	}
+}
@@ -1,65 +1,150 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"time"
 
 	"github.com/psidex/nomad/internal/graphs"
+	"github.com/psidex/nomad/internal/graphs/dot"
+	"github.com/psidex/nomad/internal/graphs/gexf"
+	"github.com/psidex/nomad/internal/graphs/graphml"
 	"github.com/psidex/nomad/internal/graphs/graphology"
+	"github.com/psidex/nomad/internal/graphs/postgres"
+	"github.com/psidex/nomad/internal/graphs/sqlite"
 	"github.com/psidex/nomad/internal/graphs/vis"
-	"github.com/psidex/nomad/internal/lib"
 	"github.com/psidex/nomad/internal/nomad"
 )
 
-// TODO: These should be configurable
-var (
-	initialUrls            = []string{"https://www.france.fr/"}
-	runtime                = time.Second * 15
-	workerCooldown         = lib.DurationFrom(time.Millisecond * 5000)
-	workerCount       uint = 3
-	graphProvider          = "vis"
-	httpClientTimeout      = time.Second * 10
-	randomCrawl            = false
-	filename               = "nomaddata"
-)
-
-func main() {
-	var chosenGraph graphs.CliGraphProvider
-	switch graphProvider {
+func (c cliConfig) newGraphProvider(name string) (graphs.CliGraphProvider, error) {
+	switch name {
 	case "echarts":
-		chosenGraph = graphs.NewECharts()
+		return graphs.NewECharts(c.Offline, c.CollapseDirection), nil
 	case "vis":
-		chosenGraph = vis.NewVis()
+		return vis.NewVis(vis.Options{Offline: c.Offline}), nil
 	case "json":
-		chosenGraph = graphs.NewHostnameGraph()
+		return graphs.NewHostnameGraph(), nil
 	case "graphology":
-		chosenGraph = graphology.NewGraphology()
+		return graphology.NewGraphology(c.CollapseDirection), nil
+	case "dot":
+		return dot.NewDot(c.Dot), nil
+	case "gexf":
+		return gexf.NewGEXF(), nil
+	case "graphml":
+		return graphml.NewGraphML(), nil
 	default:
-		log.Fatalf("unknown graph provider: %s", graphProvider)
+		return nil, fmt.Errorf("unknown graph provider: %s", name)
 	}
+}
+
+// takeSnapshots renders every output each interval until done is closed.
+func takeSnapshots(done <-chan struct{}, g *graphs.Multi, filename string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := g.RenderToFiles(filename); err != nil {
+				log.Printf("Snapshot failed: %s\n", err)
+			}
+		}
+	}
+}
+
+func main() {
+	cfg, printConfig, err := parseConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	if printConfig {
+		if err := writeConfig(os.Stdout, cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	outputs, err := cfg.outputs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.SQLite != "" {
+		sink, err := sqlite.NewSQLite(cfg.SQLite)
+		if err != nil {
+			log.Fatal(err)
+		}
+		outputs = append(outputs, graphs.Output{Provider: sink})
+	}
+	if cfg.Postgres != "" {
+		session := cfg.PostgresSession
+		if session == "" {
+			session = postgres.NewSessionId()
+		}
+		sink, err := postgres.NewPostgres(context.Background(), cfg.Postgres, session)
+		if err != nil {
+			// Close the SQLite sink if there is one, log.Fatal exits without cleaning up.
+			graphs.NewMulti(outputs...).Close()
+			log.Fatal(err)
+		}
+		outputs = append(outputs, graphs.Output{Provider: sink})
+	}
+	chosenGraph := graphs.NewMulti(outputs...)
 
 	n := nomad.NewNomad(
-		nomad.Config{
-			WorkerCooldown: workerCooldown,
-			WorkerCount:    workerCount,
-			InitialUrls:    initialUrls,
-			RandomCrawl:    randomCrawl,
-		},
+		cfg.Config,
 		&http.Client{
-			Timeout: httpClientTimeout,
+			Timeout: cfg.HttpClientTimeout.Duration,
 		},
 		chosenGraph,
 	)
 
-	if err := n.Run(); err != nil {
-		panic(err)
+	// Stop after runtime (or once the frontier is exhausted if runtime is 0), or early on
+	// Ctrl+C, and still render what we have.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if cfg.Runtime.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Runtime.Duration)
+		defer cancel()
+	}
+
+	if err := n.Run(ctx); err != nil {
+		// Nothing has been recorded, but the database sinks still have to be closed.
+		chosenGraph.Close()
+		log.Fatal(err)
+	}
+
+	// Wait for any snapshot in progress to finish so it can't overwrite the final render.
+	snapshotsDone := make(chan struct{})
+	if cfg.SnapshotInterval.Duration > 0 {
+		go func() {
+			defer close(snapshotsDone)
+			takeSnapshots(n.Done(), chosenGraph, cfg.Filename, cfg.SnapshotInterval.Duration)
+		}()
+	} else {
+		close(snapshotsDone)
 	}
 
-	time.Sleep(runtime)
-	n.Cancel()
+	summary, err := n.Wait()
+	<-snapshotsDone
+	log.Printf("Crawled %d URLs in %s, stopped by: %s (%v)\n",
+		summary.UrlsCrawled, summary.Stopped.Sub(summary.Started), summary.StopReason, err)
+	log.Printf("Frontier: %d queued, %d visited, estimated visited error rate: %g\n",
+		summary.Frontier.Queued, summary.Frontier.Visited, summary.Frontier.VisitedFalsePositiveRate)
 
-	if err := chosenGraph.RenderToFile(filename); err != nil {
-		panic(err)
+	// Close the database sinks even if rendering fails, so their last batch is written.
+	renderErr := chosenGraph.RenderToFiles(cfg.Filename)
+	if err := errors.Join(renderErr, chosenGraph.Close()); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"log"
@@ -17,6 +18,8 @@ import (
 
 var (
 	upgrader = websocket.Upgrader{}
+	// maxRuntime caps how long a session can crawl for, whatever runtime it asks for.
+	maxRuntime time.Duration
 )
 
 func main() {
@@ -24,8 +27,12 @@ func main() {
 
 	staticDir := flag.String("d", "public", "the directory to serve static files from")
 	address := flag.String("b", "127.0.0.1:8080", "the ip:port to bind the webserver to")
+	flag.DurationVar(&maxRuntime, "t", time.Hour, "the longest a session can crawl for")
 
 	flag.Parse()
+	if maxRuntime <= 0 {
+		log.Fatal("-t must be more than 0")
+	}
 
 	http.Handle("/", http.FileServer(http.Dir(*staticDir)))
 	http.HandleFunc("/ws", nomadSession)
@@ -33,6 +40,16 @@ func main() {
 	log.Fatal(http.ListenAndServe(*address, nil))
 }
 
+// sessionRuntime returns how long a session that asked for requested can crawl for. A
+// request of 0 means run until the frontier is exhausted, but never for longer than
+// maxRuntime.
+func sessionRuntime(requested time.Duration) time.Duration {
+	if requested > 0 {
+		return min(requested, maxRuntime)
+	}
+	return maxRuntime
+}
+
 func nomadSession(w http.ResponseWriter, r *http.Request) {
 	c, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -49,46 +66,49 @@ func nomadSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg := &webserver.SessionConfig{}
+	cfg := &webserver.SessionConfig{CollapseDirection: true}
 	if err = json.Unmarshal(msg, cfg); err != nil {
 		log.Println("ws cfg unmarshal err:", err)
 		return
 	}
 
+	// Checkpoints and the disk frontier are written to the server's filesystem, clients
+	// shouldn't be able to choose where or whether.
+	cfg.CheckpointFile = ""
+	cfg.Resume = false
+	cfg.FrontierPath = ""
+	// The rest of the frontier and visited set options size what the session can hold
+	// in memory, so every session uses the defaults.
+	cfg.Frontier = ""
+	cfg.FrontierMemoryLimit = 0
+	cfg.BloomCapacity = 0
+	cfg.BloomFalsePositiveRate = 0
+
 	n := nomad.NewNomad(
-		nomad.Config{
-			WorkerCooldown: cfg.WorkerCooldown,
-			WorkerCount:    cfg.WorkerCount,
-			InitialUrls:    cfg.InitialUrls,
-			RandomCrawl:    cfg.RandomCrawl,
-		},
+		cfg.Config,
 		&http.Client{
 			Timeout: cfg.HttpClientTimeout.Duration,
 		},
-		graphologyws.NewGraphologyWs(ws),
+		graphologyws.NewGraphologyWs(ws, cfg.CollapseDirection),
 	)
 
-	if err := n.Run(); err != nil {
+	ctx, cancel := context.WithTimeout(r.Context(), sessionRuntime(cfg.Runtime.Duration))
+	defer cancel()
+
+	if err := n.Run(ctx); err != nil {
 		log.Println("nomad run err:", err)
 		return
 	}
 
-	wsrecv := make(chan struct{})
-	timer := time.NewTimer(cfg.Runtime.Duration)
-
 	go func() {
 		// Client can send anything and it will cancel the session.
 		// Warning: As this is the thread-safe version, this will block any other reads.
 		_, _, _ = ws.ReadMessage()
 		// If we never read a message, the outer function call will return, closing the
 		// WS and causing ReadMessage to return an error, which will end this goroutine.
-		close(wsrecv)
+		n.Cancel()
 	}()
 
-	select {
-	case <-timer.C:
-	case <-wsrecv:
-	}
-
-	n.Cancel()
+	summary, err := n.Wait()
+	log.Printf("nomad session stopped by: %s (%v)\n", summary.StopReason, err)
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSessionRuntime(t *testing.T) {
+	maxRuntime = time.Hour
+	for _, test := range []struct {
+		requested, expected time.Duration
+	}{
+		{0, time.Hour},
+		{time.Minute, time.Minute},
+		{time.Hour, time.Hour},
+		{2 * time.Hour, time.Hour},
+		{-time.Minute, time.Hour},
+	} {
+		if got := sessionRuntime(test.requested); got != test.expected {
+			t.Errorf("requested %s got %s, expected %s", test.requested, got, test.expected)
+		}
+	}
+}
+
+func TestSessionEndsAtMaxRuntime(t *testing.T) {
+	// The crawled site never finishes responding, so only the cap can end the session.
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer site.Close()
+
+	maxRuntime = 200 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(nomadSession))
+	defer server.Close()
+
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The session asks for longer than it's allowed.
+	cfg := `{"workerCount": 1, "initialUrls": ["` + site.URL + `"], "runtime": "1h"}`
+	if err := c.WriteMessage(websocket.TextMessage, []byte(cfg)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server closes the connection when the session ends, or the deadline is hit if
+	// it doesn't.
+	started := time.Now()
+	c.SetReadDeadline(started.Add(10 * time.Second))
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("the session took %s to end, expected about %s", elapsed, maxRuntime)
+	}
+}
@@ -11,12 +11,15 @@ const deadEndNodeColour = '#db4139';
 const initialNodeSize = 2;
 const maxNodeSize = 10;
 const nodeSizeIncrease = (i: number) => i + 0.2;
+// The same as graphs.EdgeSize, edges grow logarithmically with how many links they are.
+const edgeSize = (weight: number) => Math.min(2 + Math.log2(Math.max(weight, 1)), 8);
 
 declare interface NomadSessionConfig {
   workerCooldown: string;
   workerCount: number;
   initialUrls: string[];
   randomCrawl: boolean;
+  respectRobotsTxt: boolean;
   runtime: string;
   httpClientTimeout: string;
 }
@@ -91,6 +94,7 @@ export default function Sidebar() {
       workerCount: Number(formCfg.workerCount),
       initialUrls: [formCfg.initialUrls.toString()],
       randomCrawl: formCfg.randomCrawl === 'true',
+      respectRobotsTxt: formCfg.respectRobotsTxt === 'true',
       runtime: formCfg.runtime.toString(),
       httpClientTimeout: formCfg.httpClientTimeout.toString(),
     };
@@ -136,8 +140,28 @@ export default function Sidebar() {
             };
           });
           break;
-        case 'edge':
-          sigma.getGraph().addEdge(msg.data.from, msg.data.to);
+        case 'edge': {
+          const attributes = {
+            kind: msg.data.kind,
+            color: msg.data.color,
+            weight: msg.data.weight,
+            size: edgeSize(msg.data.weight),
+          };
+          if (msg.data.undirected) {
+            sigma.getGraph().addUndirectedEdgeWithKey(msg.data.key, msg.data.from, msg.data.to, attributes);
+          } else {
+            sigma.getGraph().addDirectedEdgeWithKey(msg.data.key, msg.data.from, msg.data.to, attributes);
+          }
+          break;
+        }
+        case 'edgeupdate':
+          sigma.getGraph().updateEdgeAttributes(msg.data.key, (attr) => ({
+            ...attr,
+            ...{
+              weight: msg.data.weight,
+              size: edgeSize(msg.data.weight),
+            },
+          }));
           break;
         case 'endcrawl': {
           if (msg.data.deadend === true) {
@@ -197,6 +221,11 @@ export default function Sidebar() {
         <input name="randomCrawl" id="randomCrawlInput" type="text" defaultValue="false" />
       </div>
 
+      <div className="sidebar-option">
+        <label htmlFor="respectRobotsTxtInput">Respect robots.txt</label>
+        <input name="respectRobotsTxt" id="respectRobotsTxtInput" type="text" defaultValue="true" />
+      </div>
+
       <div className="sidebar-option">
         <label htmlFor="runtimeInput">Runtime</label>
         <input name="runtime" id="runtimeInput" type="text" defaultValue="10s" />
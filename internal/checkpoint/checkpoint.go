@@ -0,0 +1,284 @@
+package checkpoint
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/psidex/nomad/internal/frontier"
+)
+
+// restoreBatchSize is how many queued entries or visited URLs Load restores at once.
+const restoreBatchSize = 1000
+
+// Checkpoint is a snapshot of a crawl that it can be resumed from. The frontier's queued
+// entries and visited URLs are streamed to and from the file, so they're not part of it.
+type Checkpoint struct {
+	Created time.Time `json:"created"`
+	// Edges are the hostname connections recorded so far, in the order they were
+	// first seen.
+	Edges []Edge `json:"edges"`
+	// Hostnames and HostsFetched restore the crawl budgets.
+	Hostnames    []string `json:"hostnames"`
+	HostsFetched uint64   `json:"hostsFetched"`
+	// Queued and Visited are how many of each the frontier had, set by Save and Load.
+	Queued  int `json:"-"`
+	Visited int `json:"-"`
+}
+
+// Restorer is what Load restores a frontier snapshot in to, in batches.
+type Restorer interface {
+	RestoreVisited(urls []string, filter []byte) error
+	RestoreQueued(entries []frontier.Entry)
+}
+
+var _ Restorer = (*frontier.Frontier)(nil)
+
+// Save atomically writes cp and the frontier snapshot to filename, replacing any
+// existing checkpoint. If filename ends with .gz the checkpoint is gzipped.
+func Save(filename string, cp *Checkpoint, snapshot *frontier.Snapshot) error {
+	// Write to a temporary file in the same directory and then rename it, so a crash
+	// while saving never leaves a corrupt checkpoint behind.
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := encode(tmp, filename, cp, snapshot); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+func encode(w io.Writer, filename string, cp *Checkpoint, snapshot *frontier.Snapshot) error {
+	var gz *gzip.Writer
+	if strings.HasSuffix(filename, ".gz") {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+	bw := bufio.NewWriter(w)
+	if err := encodeJSON(bw, cp, snapshot); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+// encodeJSON writes cp as a JSON object with the snapshot's visited set and queue added
+// to the end, one element at a time. The visited set comes first so that Load restores
+// it before any of the queue.
+func encodeJSON(w *bufio.Writer, cp *Checkpoint, snapshot *frontier.Snapshot) error {
+	header, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	// Reopen the object to add the snapshot's fields.
+	w.Write(header[:len(header)-1])
+
+	filter, err := snapshot.VisitedFilter()
+	if err != nil {
+		return err
+	}
+	if filter != nil {
+		value, err := json.Marshal(filter)
+		if err != nil {
+			return err
+		}
+		w.WriteString(`,"visitedFilter":`)
+		w.Write(value)
+	}
+
+	cp.Visited = 0
+	w.WriteString(`,"visited":[`)
+	err = snapshot.EachVisited(func(url string) error {
+		cp.Visited++
+		return writeElement(w, cp.Visited, url)
+	})
+	if err != nil {
+		return err
+	}
+
+	cp.Queued = 0
+	w.WriteString(`],"queued":[`)
+	err = snapshot.EachQueued(func(entry frontier.Entry) error {
+		cp.Queued++
+		return writeElement(w, cp.Queued, entry)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.WriteString("]}\n")
+	return err
+}
+
+// writeElement writes the nth (starting from 1) element of a JSON array.
+func writeElement(w *bufio.Writer, n int, v any) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if n > 1 {
+		w.WriteByte(',')
+	}
+	_, err = w.Write(value)
+	return err
+}
+
+// Load reads a checkpoint written by Save, restoring its frontier snapshot in to r.
+func Load(filename string, r Restorer) (*Checkpoint, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = bufio.NewReader(file)
+	if strings.HasSuffix(filename, ".gz") {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	cp := &Checkpoint{}
+	if err := decode(json.NewDecoder(reader), cp, r); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// decode reads the checkpoint object one field at a time, so that the frontier's fields
+// can be restored in batches instead of all at once.
+func decode(dec *json.Decoder, cp *Checkpoint, r Restorer) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+
+		switch key {
+		case "visitedFilter":
+			var filter []byte
+			if err := dec.Decode(&filter); err != nil {
+				return err
+			}
+			if err := r.RestoreVisited(nil, filter); err != nil {
+				return err
+			}
+		case "visited":
+			var batch []string
+			err := decodeArray(dec, func() error {
+				var url string
+				if err := dec.Decode(&url); err != nil {
+					return err
+				}
+				cp.Visited++
+				if batch = append(batch, url); len(batch) == restoreBatchSize {
+					err := r.RestoreVisited(batch, nil)
+					batch = batch[:0]
+					return err
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if err := r.RestoreVisited(batch, nil); err != nil {
+				return err
+			}
+		case "queued":
+			var batch []frontier.Entry
+			err := decodeArray(dec, func() error {
+				var entry frontier.Entry
+				if err := dec.Decode(&entry); err != nil {
+					return err
+				}
+				cp.Queued++
+				if batch = append(batch, entry); len(batch) == restoreBatchSize {
+					r.RestoreQueued(batch)
+					batch = batch[:0]
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			r.RestoreQueued(batch)
+		default:
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return err
+			}
+			fields[key] = value
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+
+	// Everything else is small enough to decode normally.
+	rest, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(rest, cp)
+}
+
+// decodeArray calls fn to decode each element of the array dec is at, null is treated as
+// an empty array.
+func decodeArray(dec *json.Decoder, fn func() error) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("invalid checkpoint, expected [ but got %v", token)
+	}
+	for dec.More() {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("invalid checkpoint, expected %v but got %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/psidex/nomad/internal/frontier"
+	"github.com/psidex/nomad/internal/graphs"
+	. "github.com/psidex/nomad/internal/lib"
+)
+
+func testUrl(i int) string {
+	return fmt.Sprintf("https://%d.example.com", i)
+}
+
+// newCrawledFrontier returns a frontier with queued URLs, visited URLs, and one in
+// progress.
+func newCrawledFrontier(t *testing.T) *frontier.Frontier {
+	t.Helper()
+	f := frontier.NewFrontier(false, NewSet())
+	for i := 0; i < 2500; i++ {
+		f.AddUrl(testUrl(i), uint(i%3))
+	}
+	for i := 0; i < 1500; i++ {
+		entry, err := f.PopUrl(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 {
+			f.Done(entry.Url)
+		}
+	}
+	return f
+}
+
+func TestSaveLoad(t *testing.T) {
+	for _, filename := range []string{"checkpoint.json", "checkpoint.json.gz"} {
+		t.Run(filename, func(t *testing.T) {
+			filename = filepath.Join(t.TempDir(), filename)
+
+			f := newCrawledFrontier(t)
+			snapshot, err := f.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer snapshot.Close()
+
+			cp := &Checkpoint{
+				Created:      time.Now().Truncate(time.Second),
+				Edges:        []Edge{{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor, Count: 3}},
+				Hostnames:    []string{"a.com", "b.com"},
+				HostsFetched: 1500,
+			}
+			if err := Save(filename, cp, snapshot); err != nil {
+				t.Fatal(err)
+			}
+			// 1 of the popped URLs is still in progress, so it's queued.
+			if cp.Queued != 1001 || cp.Visited != 1499 {
+				t.Errorf("saved %d queued and %d visited, expected 1001 and 1499", cp.Queued, cp.Visited)
+			}
+
+			restored := frontier.NewFrontier(false, NewSet())
+			loaded, err := Load(filename, restored)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !loaded.Created.Equal(cp.Created) || loaded.HostsFetched != cp.HostsFetched ||
+				fmt.Sprint(loaded.Edges) != fmt.Sprint(cp.Edges) || fmt.Sprint(loaded.Hostnames) != fmt.Sprint(cp.Hostnames) {
+				t.Errorf("loaded %+v, expected %+v", loaded, cp)
+			}
+			if loaded.Queued != cp.Queued || loaded.Visited != cp.Visited {
+				t.Errorf("loaded %d queued and %d visited, expected %d and %d", loaded.Queued, loaded.Visited, cp.Queued, cp.Visited)
+			}
+			if stats := restored.Stats(); stats.Queued != 1001 || stats.Visited != 1499 {
+				t.Errorf("restored frontier has %d queued and %d visited, expected 1001 and 1499", stats.Queued, stats.Visited)
+			}
+
+			var popped []string
+			for {
+				entry, err := restored.PopUrl(context.Background())
+				if err != nil {
+					break
+				}
+				popped = append(popped, entry.Url)
+				restored.Done(entry.Url)
+			}
+			if len(popped) != 1001 {
+				t.Errorf("popped %d URLs from the restored frontier, expected 1001", len(popped))
+			}
+			sort.Strings(popped)
+			if i := sort.SearchStrings(popped, testUrl(0)); i == len(popped) || popped[i] != testUrl(0) {
+				t.Error("the URL that was in progress wasn't restored")
+			}
+		})
+	}
+}
+
+func TestLoadVisitedFilter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	f := frontier.NewFrontier(false, NewBloomSet(1000, 0.001))
+	f.AddUrl(testUrl(0), 0)
+	f.AddUrl(testUrl(1), 0)
+	entry, err := f.PopUrl(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Done(entry.Url)
+
+	snapshot, err := f.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+	if err := Save(filename, &Checkpoint{}, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := frontier.NewFrontier(false, NewBloomSet(1000, 0.001))
+	if _, err := Load(filename, restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.AddUrl(testUrl(0), 0) {
+		t.Error("the visited URL was added again")
+	}
+	if restored.Size() != 1 {
+		t.Errorf("restored frontier has %d queued, expected 1", restored.Size())
+	}
+}
+
+func TestLoadOldFormat(t *testing.T) {
+	// Checkpoints used to be written in one go, with the queue first and null if empty.
+	filename := filepath.Join(t.TempDir(), "checkpoint.json")
+	old := `{"created":"2024-01-01T00:00:00Z","queued":[{"Url":"https://b.com","Depth":1,"Attempts":0}],` +
+		`"visited":["https://a.com"],"edges":[{"from":"a.com","to":"b.com"}],"hostnames":null,"hostsFetched":1}`
+	if err := os.WriteFile(filename, []byte(old), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := frontier.NewFrontier(false, NewSet())
+	cp, err := Load(filename, restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Queued != 1 || cp.Visited != 1 || len(cp.Edges) != 1 || cp.HostsFetched != 1 {
+		t.Errorf("loaded %+v", cp)
+	}
+	if restored.AddUrl("https://a.com", 0) {
+		t.Error("the visited URL was added again")
+	}
+}
@@ -0,0 +1,54 @@
+package checkpoint
+
+import (
+	"sync"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// Edge is a connection between two hostnames, the kind of link it was found in, and how
+// many links there were.
+type Edge struct {
+	From  string          `json:"from"`
+	To    string          `json:"to"`
+	Kind  graphs.LinkKind `json:"kind,omitempty"`
+	Count int             `json:"count,omitempty"`
+}
+
+// EdgeLog records each unique hostname connection and kind in the order it was first
+// seen, counting the links, so they can be replayed into a graph provider when resuming.
+// EdgeLog is thread-safe and should be held as a pointer.
+type EdgeLog struct {
+	mu    *sync.Mutex
+	index map[string]int
+	edges []Edge
+}
+
+func NewEdgeLog() *EdgeLog {
+	return &EdgeLog{
+		mu:    &sync.Mutex{},
+		index: make(map[string]int),
+	}
+}
+
+func (l *EdgeLog) Add(fromHost, toHost string, kind graphs.LinkKind, count int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Use tab as a separator as it can't appear in hostnames.
+	key := fromHost + "\t" + toHost + "\t" + string(kind)
+	if i, ok := l.index[key]; ok {
+		l.edges[i].Count += count
+		return
+	}
+	l.index[key] = len(l.edges)
+	l.edges = append(l.edges, Edge{From: fromHost, To: toHost, Kind: kind, Count: count})
+}
+
+// Edges returns a copy of the recorded edges.
+func (l *EdgeLog) Edges() []Edge {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	edges := make([]Edge, len(l.edges))
+	copy(edges, l.edges)
+	return edges
+}
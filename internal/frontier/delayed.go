@@ -0,0 +1,79 @@
+package frontier
+
+import (
+	"container/heap"
+	"net/url"
+	"time"
+)
+
+// maxDelayed caps how many entries a Frontier holds back because their host isn't ready,
+// as they're kept in memory. Past it, entries are handed out anyway and the caller has to
+// wait for the Scheduler.
+const maxDelayed = 1000
+
+// delayedEntry is an entry held back until its host is ready at.
+type delayedEntry struct {
+	entry Entry
+	at    time.Time
+}
+
+// delayedQueue is a min-heap of delayed entries, the one that's ready first is at the top.
+type delayedQueue []delayedEntry
+
+var _ heap.Interface = (*delayedQueue)(nil)
+
+func (q delayedQueue) Len() int           { return len(q) }
+func (q delayedQueue) Less(i, j int) bool { return q[i].at.Before(q[j].at) }
+func (q delayedQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *delayedQueue) Push(x any) {
+	*q = append(*q, x.(delayedEntry))
+}
+
+func (q *delayedQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+// readyAt returns when a request can be made to entry's host, which is the zero time if
+// there's no scheduler or the URL can't be parsed.
+func (f *Frontier) readyAt(entry Entry) time.Time {
+	if f.scheduler == nil {
+		return time.Time{}
+	}
+	parsed, err := url.Parse(entry.Url)
+	if err != nil {
+		return time.Time{}
+	}
+	return f.scheduler.ReadyAt(parsed.Hostname())
+}
+
+// popReady returns the next entry whose host is ready at now, either one that was
+// delayed or the next unvisited entry from the queue. Entries from the queue that aren't
+// ready are delayed until they are. ok is false if there isn't one, f.mu must be held.
+func (f *Frontier) popReady(now time.Time) (entry Entry, ok bool) {
+	for f.delayed.Len() > 0 && !f.delayed[0].at.After(now) {
+		delayed := heap.Pop(&f.delayed).(delayedEntry)
+		// The host may have been backed off again since it was delayed.
+		if at := f.readyAt(delayed.entry); at.After(now) {
+			heap.Push(&f.delayed, delayedEntry{entry: delayed.entry, at: at})
+			continue
+		}
+		return delayed.entry, true
+	}
+
+	for {
+		entry, ok = f.popUnvisited()
+		if !ok {
+			return entry, false
+		}
+		f.store.Visit(entry.Url)
+		at := f.readyAt(entry)
+		if !at.After(now) || f.delayed.Len() >= maxDelayed {
+			return entry, true
+		}
+		heap.Push(&f.delayed, delayedEntry{entry: entry, at: at})
+	}
+}
@@ -0,0 +1,316 @@
+package frontier
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"os"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	queueBucket   = []byte("queue")
+	visitedBucket = []byte("visited")
+	// present is the value stored for each visited URL, only the key matters.
+	present = []byte{1}
+)
+
+// diskStore is a Store that keeps at most memoryLimit queued entries in memory and
+// spills the rest, along with every visited URL, to a bbolt database.
+//
+// The queue is split into three parts: head is read in batches from the database and is
+// where entries are popped from, the database holds the middle, and tail buffers new
+// entries until there's enough to write them in one transaction. While the database is
+// empty, new entries go straight in to head. Tail holds at most batchSize entries and
+// head at most headLimit, which add up to memoryLimit.
+//
+// Random pops can only choose from head, so they're only random within the oldest
+// headLimit entries.
+type diskStore struct {
+	db          *bolt.DB
+	path        string
+	removeOnEnd bool
+	memoryLimit int
+	head        []Entry
+	tail        []Entry
+	// onDisk is the number of entries in the queue bucket, and nextSeq is the key for
+	// the next one written.
+	onDisk  int
+	nextSeq uint64
+}
+
+var _ Store = (*diskStore)(nil)
+
+// newDiskStore opens a new diskStore at path, replacing anything already there. If path
+// is empty a temporary file is used and removed on Close.
+func newDiskStore(path string, memoryLimit int) (*diskStore, error) {
+	removeOnEnd := false
+	if path == "" {
+		f, err := os.CreateTemp("", "nomadfrontier*.db")
+		if err != nil {
+			return nil, err
+		}
+		path = f.Name()
+		f.Close()
+		removeOnEnd = true
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The frontier is rebuilt from scratch (or a checkpoint) each run, so we don't need
+	// every write to be durable.
+	db.NoSync = true
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucket(queueBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(visitedBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &diskStore{
+		db:          db,
+		path:        path,
+		removeOnEnd: removeOnEnd,
+		memoryLimit: max(memoryLimit, 2),
+	}, nil
+}
+
+// batchSize is how many entries are moved between memory and disk at once.
+func (s *diskStore) batchSize() int {
+	return max(s.memoryLimit/2, 1)
+}
+
+// headLimit is how many entries head can hold, which leaves room for a full tail.
+func (s *diskStore) headLimit() int {
+	return max(s.memoryLimit-s.batchSize(), 1)
+}
+
+func (s *diskStore) Push(entry Entry) {
+	if s.onDisk == 0 && len(s.tail) == 0 && len(s.head) < s.headLimit() {
+		s.head = append(s.head, entry)
+		return
+	}
+	s.tail = append(s.tail, entry)
+	if len(s.tail) >= s.batchSize() {
+		s.flushTail()
+	}
+}
+
+// flushTail writes every entry in tail to the database.
+func (s *diskStore) flushTail() {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(queueBucket)
+		for _, entry := range s.tail {
+			value, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(seqKey(s.nextSeq), value); err != nil {
+				return err
+			}
+			s.nextSeq++
+		}
+		return nil
+	})
+	if err != nil {
+		// Keep the entries in memory rather than lose them.
+		log.Printf("Could not write frontier entries to disk, err: %v\n", err)
+		return
+	}
+	s.onDisk += len(s.tail)
+	s.tail = s.tail[:0]
+}
+
+// fillHead reads the oldest batch of entries from the database in to head, falling
+// back to tail if the database is empty.
+func (s *diskStore) fillHead() {
+	if s.onDisk == 0 {
+		s.head = append(s.head, s.tail...)
+		s.tail = s.tail[:0]
+		return
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(queueBucket).Cursor()
+		for k, v := c.First(); k != nil && len(s.head) < s.headLimit(); k, v = c.First() {
+			var entry Entry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			s.head = append(s.head, entry)
+			s.onDisk--
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Could not read frontier entries from disk, err: %v\n", err)
+	}
+}
+
+func (s *diskStore) Pop(random bool) (entry Entry, ok bool) {
+	if len(s.head) == 0 {
+		s.fillHead()
+	}
+	if len(s.head) == 0 {
+		return entry, false
+	}
+
+	i := 0
+	if random {
+		i = rand.Intn(len(s.head))
+	}
+	entry = s.head[i]
+	s.head = append(s.head[:i], s.head[i+1:]...)
+	return entry, true
+}
+
+func (s *diskStore) Len() int {
+	return len(s.head) + s.onDisk + len(s.tail)
+}
+
+func (s *diskStore) Visit(url string) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(visitedBucket).Put([]byte(url), present)
+	})
+	if err != nil {
+		log.Printf("Could not write visited URL to disk, err: %v\n", err)
+	}
+}
+
+func (s *diskStore) Unvisit(url string) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(visitedBucket).Delete([]byte(url))
+	})
+	if err != nil {
+		log.Printf("Could not remove visited URL from disk, err: %v\n", err)
+	}
+}
+
+func (s *diskStore) Visited(url string) (visited bool) {
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		visited = tx.Bucket(visitedBucket).Get([]byte(url)) != nil
+		return nil
+	})
+	return visited
+}
+
+func (s *diskStore) VisitedCount() (count int) {
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(visitedBucket).Stats().KeyN
+		return nil
+	})
+	return count
+}
+
+func (s *diskStore) FalsePositiveRate() float64 {
+	return 0
+}
+
+func (s *diskStore) LoadVisited(urls []string, filter []byte) error {
+	if filter != nil {
+		return errors.New("the disk frontier can't load a filter visited set")
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(visitedBucket)
+		for _, url := range urls {
+			if err := b.Put([]byte(url), present); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Snapshot copies the entries held in memory, which there are at most memoryLimit of,
+// and opens a read transaction for everything on disk. bbolt keeps what a read
+// transaction sees the same until it's closed, so nothing is loaded in to memory.
+func (s *diskStore) Snapshot() (StoreSnapshot, error) {
+	tx, err := s.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return &diskSnapshot{
+		tx:   tx,
+		head: append([]Entry(nil), s.head...),
+		tail: append([]Entry(nil), s.tail...),
+	}, nil
+}
+
+func (s *diskStore) Close() error {
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	if s.removeOnEnd {
+		return os.Remove(s.path)
+	}
+	return nil
+}
+
+// seqKey encodes seq so that keys sort in insertion order.
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// diskSnapshot is a diskStore's StoreSnapshot. While it's open the database can't grow
+// its memory map, so writes that need to will wait until it's closed.
+type diskSnapshot struct {
+	tx         *bolt.Tx
+	head, tail []Entry
+}
+
+func (s *diskSnapshot) EachEntry(fn func(Entry) error) error {
+	for _, entry := range s.head {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	err := s.tx.Bucket(queueBucket).ForEach(func(_, v []byte) error {
+		var entry Entry
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return err
+		}
+		return fn(entry)
+	})
+	if err != nil {
+		return err
+	}
+	for _, entry := range s.tail {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *diskSnapshot) EachVisited(fn func(url string) error) error {
+	return s.tx.Bucket(visitedBucket).ForEach(func(k, _ []byte) error {
+		return fn(string(k))
+	})
+}
+
+func (s *diskSnapshot) VisitedFilter() FilterSet {
+	return nil
+}
+
+func (s *diskSnapshot) Close() error {
+	return s.tx.Rollback()
+}
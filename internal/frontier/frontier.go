@@ -1,72 +1,323 @@
 package frontier
 
 import (
+	"context"
+	"errors"
 	"sync"
+	"time"
+)
 
-	. "github.com/psidex/nomad/internal/lib"
+var (
+	// ErrExhausted is returned by PopUrl once the frontier is empty and no popped URLs
+	// are still being processed, meaning no more URLs can ever be added.
+	ErrExhausted = errors.New("frontier exhausted")
+	// ErrStopped is returned by PopUrl once Stop has been called.
+	ErrStopped = errors.New("frontier stopped")
 )
 
+// Entry is a URL in the frontier along with how many hops it is from the initial URLs,
+// and how many times it has been retried.
+type Entry struct {
+	Url      string
+	Depth    uint
+	Attempts uint
+}
+
+// Frontier is thread-safe and should be held as a pointer.
 type Frontier struct {
 	random bool
-	queue  *Queue
-	// visitedMu is for synchronicity rather than thread safety, it's possible for 2 of
-	// the same URL to exist the queue next to eachother, and for 2 PopUrl calls to
-	// happen at exactly the same time; without the mutex and with some bad luck, a
-	// duplicated URL could sneak past between checking if it's visited and adding to
-	// the visited set.
-	visitedMu *sync.Mutex
-	visited   Set
-}
-
-// NewFrontier creates a new Frontier. The random parameter determines if the popped
-// URLs are random or FIFO.
-func NewFrontier(random bool) Frontier {
-	return Frontier{
-		random:    random,
-		queue:     NewQueue(),
-		visitedMu: &sync.Mutex{},
-		visited:   NewSet(),
+	// mu is for synchronicity as well as thread safety, it's possible for 2 of the same
+	// URL to exist the queue next to eachother, and for 2 PopUrl calls to happen at
+	// exactly the same time; without the mutex and with some bad luck, a duplicated URL
+	// could sneak past between checking if it's visited and adding to the visited set.
+	// It also protects inProgress, delayed, stopped, and wake.
+	mu    *sync.Mutex
+	store Store
+	// scheduler is used to skip hosts that aren't ready, see SetScheduler.
+	scheduler *Scheduler
+	// inProgress holds the entries that have been popped but not marked as Done.
+	inProgress map[string]Entry
+	// delayed holds entries taken from the store whose host wasn't ready, they're
+	// already marked as visited.
+	delayed delayedQueue
+	stopped bool
+	// wake is closed and replaced whenever something changes that a blocked PopUrl
+	// might be waiting for.
+	wake chan struct{}
+}
+
+// NewFrontier creates a new in-memory Frontier using visited to remember which URLs have
+// been popped, e.g. lib.NewSet() or lib.NewBloomSet(...). The random parameter
+// determines if the popped URLs are random or FIFO.
+func NewFrontier(random bool, visited VisitedSet) *Frontier {
+	return newFrontier(random, newMemoryStore(visited))
+}
+
+// NewDiskFrontier creates a new Frontier that keeps at most memoryLimit queued URLs in
+// memory and stores the rest, along with the visited URLs, in a database at path. Any
+// existing database at path is replaced, and if path is empty a temporary file is used.
+// When random is true, URLs are only chosen randomly from about the oldest half of
+// memoryLimit. Up to maxDelayed URLs held back for their host are also kept in memory.
+func NewDiskFrontier(random bool, path string, memoryLimit int) (*Frontier, error) {
+	store, err := newDiskStore(path, memoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	return newFrontier(random, store), nil
+}
+
+func newFrontier(random bool, store Store) *Frontier {
+	return &Frontier{
+		random:     random,
+		mu:         &sync.Mutex{},
+		store:      store,
+		inProgress: make(map[string]Entry),
+		wake:       make(chan struct{}),
 	}
 }
 
-// AddUrl adds a URL to the frontier, returns true if added, false if it's already been
-// visited.
-func (f Frontier) AddUrl(url string) bool {
-	// visitedMu is probably less important here, but might as well use it.
-	f.visitedMu.Lock()
-	defer f.visitedMu.Unlock()
-	if f.visited.Contains(url) {
+// SetScheduler makes PopUrl skip URLs whose host s isn't ready for, handing out ones
+// that are ready instead. It should be called before anything is popped.
+func (f *Frontier) SetScheduler(s *Scheduler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scheduler = s
+}
+
+// broadcast wakes up every blocked PopUrl, f.mu must be held.
+func (f *Frontier) broadcast() {
+	close(f.wake)
+	f.wake = make(chan struct{})
+}
+
+// AddUrl adds a URL to the frontier at the given depth, returns true if added, false if
+// it's already been visited.
+func (f *Frontier) AddUrl(url string, depth uint) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.store.Visited(url) {
 		return false
 	}
-	f.queue.Enqueue(url)
+	f.store.Push(Entry{Url: url, Depth: depth})
+	f.broadcast()
 	return true
 }
 
-// PopUrl gets an unvisited URL from the frontier, can return an empty string if there's
-// nothing to pop.
-func (f Frontier) PopUrl() (url string) {
-	f.visitedMu.Lock()
-	defer f.visitedMu.Unlock()
+// PopUrl gets an unvisited URL from the frontier, blocking until one is available. If
+// there's a scheduler, URLs whose host isn't ready are skipped until it is. The caller
+// must call Done with the URL once it has finished processing it. If the frontier is
+// exhausted ErrExhausted is returned, if it's stopped ErrStopped is, and if ctx is done
+// its error is.
+func (f *Frontier) PopUrl(ctx context.Context) (Entry, error) {
 	for {
-		if f.random {
-			url = f.queue.RandomDequeue()
-		} else {
-			url = f.queue.Dequeue()
+		if err := ctx.Err(); err != nil {
+			return Entry{}, err
 		}
-		if url != "" && f.visited.Contains(url) {
+
+		f.mu.Lock()
+
+		if f.stopped {
+			f.mu.Unlock()
+			return Entry{}, ErrStopped
+		}
+
+		now := time.Now()
+		if entry, ok := f.popReady(now); ok {
+			f.inProgress[entry.Url] = entry
+			f.mu.Unlock()
+			return entry, nil
+		}
+
+		if len(f.inProgress) == 0 && f.delayed.Len() == 0 {
+			f.mu.Unlock()
+			return Entry{}, ErrExhausted
+		}
+
+		// Wait for something to change, or for the first delayed entry to be ready.
+		var timer *time.Timer
+		var ready <-chan time.Time
+		if f.delayed.Len() > 0 {
+			timer = time.NewTimer(f.delayed[0].at.Sub(now))
+			ready = timer.C
+		}
+		wake := f.wake
+		f.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+		case <-wake:
+		case <-ready:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// popUnvisited returns the next entry from the queue that hasn't been visited, ok is
+// false if there isn't one, f.mu must be held.
+func (f *Frontier) popUnvisited() (entry Entry, ok bool) {
+	for {
+		entry, ok = f.store.Pop(f.random)
+		if ok && f.store.Visited(entry.Url) {
 			// 2 of the same URL can appear in the queue if, for example, 2 of the same
 			// URL are found on the same page. We could prevent this by checking the
-			// contents of f.queue in AddUrl but checking a slice for a value is O(n)...
+			// contents of the queue in AddUrl but checking a slice for a value is O(n)...
 			continue
 		}
-		f.visited.Add(url)
-		break
+		return entry, ok
 	}
-	return url
+}
+
+// Done marks a URL returned by PopUrl as finished being processed. Any URLs found while
+// processing it should be added before calling Done.
+func (f *Frontier) Done(url string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.inProgress, url)
+	f.broadcast()
+}
+
+// Requeue puts an entry returned by PopUrl back in the frontier so it can be popped
+// again, e.g. to retry it. It replaces calling Done.
+func (f *Frontier) Requeue(entry Entry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.store.Unvisit(entry.Url)
+	delete(f.inProgress, entry.Url)
+	f.store.Push(entry)
+	f.broadcast()
+}
+
+// Snapshot is the state of a frontier at the time it was taken. It can be read while the
+// frontier keeps being used, without loading the whole frontier in to memory, and must be
+// closed. Entries that were in progress or delayed are treated as queued, as they hadn't
+// finished being processed.
+type Snapshot struct {
+	pending map[string]Entry
+	store   StoreSnapshot
+}
+
+// Snapshot returns the frontier's current state.
+func (f *Frontier) Snapshot() (*Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	store, err := f.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[string]Entry, len(f.inProgress)+f.delayed.Len())
+	for url, entry := range f.inProgress {
+		pending[url] = entry
+	}
+	for _, delayed := range f.delayed {
+		pending[delayed.entry.Url] = delayed.entry
+	}
+	return &Snapshot{pending: pending, store: store}, nil
+}
+
+// EachQueued calls fn with every queued entry, stopping at the first error.
+func (s *Snapshot) EachQueued(fn func(Entry) error) error {
+	for _, entry := range s.pending {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return s.store.EachEntry(fn)
+}
+
+// EachVisited calls fn with every visited URL, stopping at the first error. It does
+// nothing if they can't be listed, VisitedFilter is set instead.
+func (s *Snapshot) EachVisited(fn func(url string) error) error {
+	return s.store.EachVisited(func(url string) error {
+		if _, ok := s.pending[url]; ok {
+			return nil
+		}
+		return fn(url)
+	})
+}
+
+// VisitedFilter returns the serialized visited set if it can't be listed, and nil if it
+// can. It's serialized here rather than in Frontier.Snapshot so the frontier isn't locked
+// while it is.
+func (s *Snapshot) VisitedFilter() ([]byte, error) {
+	filter := s.store.VisitedFilter()
+	if filter == nil {
+		return nil, nil
+	}
+	// Pending entries were visited when they were popped, so they're taken out of the
+	// copy like EachVisited leaves them out.
+	for url := range s.pending {
+		filter.Remove(url)
+	}
+	return filter.MarshalBinary()
+}
+
+func (s *Snapshot) Close() error {
+	return s.store.Close()
+}
+
+// RestoreVisited adds visited URLs, or the serialized filter, from a Snapshot. It can be
+// called more than once, and should be called before RestoreQueued and before anything
+// is popped.
+func (f *Frontier) RestoreVisited(urls []string, filter []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.store.LoadVisited(urls, filter)
+}
+
+// RestoreQueued adds queued entries from a Snapshot. It can be called more than once,
+// and should be called before anything is popped.
+func (f *Frontier) RestoreQueued(entries []Entry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, entry := range entries {
+		f.store.Push(entry)
+	}
+	f.broadcast()
+}
+
+// Stats describes the current state of a frontier.
+type Stats struct {
+	Queued     int `json:"queued"`
+	InProgress int `json:"inProgress"`
+	Visited    int `json:"visited"`
+	// VisitedFalsePositiveRate is the estimated chance of an unvisited URL being treated
+	// as visited, it's always 0 unless a probabilistic visited set is used.
+	VisitedFalsePositiveRate float64 `json:"visitedFalsePositiveRate"`
+}
+
+func (f *Frontier) Stats() Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return Stats{
+		Queued:                   f.store.Len() + f.delayed.Len(),
+		InProgress:               len(f.inProgress),
+		Visited:                  f.store.VisitedCount(),
+		VisitedFalsePositiveRate: f.store.FalsePositiveRate(),
+	}
+}
+
+// Stop stops PopUrl from returning any more URLs, any blocked calls return ErrStopped.
+func (f *Frontier) Stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+	f.broadcast()
+}
+
+// Close releases the frontier's storage, it can't be used afterwards.
+func (f *Frontier) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.store.Close()
 }
 
 // Size returns the size of the frontier, not accounting for entries that may have
 // already been visited.
-func (f Frontier) Size() int {
-	return f.queue.Size()
+func (f *Frontier) Size() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.store.Len() + f.delayed.Len()
 }
@@ -0,0 +1,377 @@
+package frontier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	. "github.com/psidex/nomad/internal/lib"
+)
+
+// frontiers creates one of each kind of frontier for a test or benchmark.
+var frontiers = map[string]func(tb testing.TB) *Frontier{
+	"memory": func(tb testing.TB) *Frontier {
+		return NewFrontier(false, NewSet())
+	},
+	"disk": func(tb testing.TB) *Frontier {
+		f, err := NewDiskFrontier(false, filepath.Join(tb.TempDir(), "frontier.db"), 1000)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return f
+	},
+}
+
+func testUrl(i int) string {
+	return fmt.Sprintf("https://%d.example.com", i)
+}
+
+func TestFrontierSnapshot(t *testing.T) {
+	for name, newFrontier := range frontiers {
+		t.Run(name, func(t *testing.T) {
+			f := newFrontier(t)
+			defer f.Close()
+			ctx := context.Background()
+
+			for i := 0; i < 5000; i++ {
+				f.AddUrl(testUrl(i), 1)
+			}
+			for i := 0; i < 10; i++ {
+				entry, err := f.PopUrl(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if i < 5 {
+					f.Done(entry.Url)
+				}
+			}
+
+			snapshot, err := f.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer snapshot.Close()
+
+			// Changes after the snapshot is taken shouldn't be in it.
+			for i := 5000; i < 6000; i++ {
+				f.AddUrl(testUrl(i), 1)
+			}
+			for i := 0; i < 100; i++ {
+				entry, err := f.PopUrl(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.Done(entry.Url)
+			}
+
+			var queued []string
+			err = snapshot.EachQueued(func(entry Entry) error {
+				queued = append(queued, entry.Url)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var visited []string
+			err = snapshot.EachVisited(func(url string) error {
+				visited = append(visited, url)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The 5 in progress are queued, not visited.
+			if len(queued) != 4995 {
+				t.Errorf("got %d queued, expected 4995", len(queued))
+			}
+			sort.Strings(visited)
+			expected := []string{testUrl(0), testUrl(1), testUrl(2), testUrl(3), testUrl(4)}
+			sort.Strings(expected)
+			if fmt.Sprint(visited) != fmt.Sprint(expected) {
+				t.Errorf("got visited %v, expected %v", visited, expected)
+			}
+		})
+	}
+}
+
+func TestFrontierRestore(t *testing.T) {
+	for name, newFrontier := range frontiers {
+		t.Run(name, func(t *testing.T) {
+			f := newFrontier(t)
+			defer f.Close()
+
+			if err := f.RestoreVisited([]string{testUrl(0)}, nil); err != nil {
+				t.Fatal(err)
+			}
+			f.RestoreQueued([]Entry{{Url: testUrl(1), Depth: 2}})
+
+			if f.AddUrl(testUrl(0), 0) {
+				t.Error("a restored visited URL was added")
+			}
+			entry, err := f.PopUrl(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Url != testUrl(1) || entry.Depth != 2 {
+				t.Errorf("popped %+v, expected the restored entry", entry)
+			}
+		})
+	}
+}
+
+func TestFrontierSnapshotFilter(t *testing.T) {
+	f := NewFrontier(false, NewBloomSet(1000, 0.01))
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		f.AddUrl(testUrl(i), 1)
+	}
+	var popped []string
+	for i := 0; i < 3; i++ {
+		entry, err := f.PopUrl(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		popped = append(popped, entry.Url)
+	}
+	f.Done(popped[0])
+
+	snapshot, err := f.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+
+	// Changes after the snapshot is taken shouldn't be in the filter.
+	f.Done(popped[1])
+	entry, err := f.PopUrl(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Done(entry.Url)
+
+	filter, err := snapshot.VisitedFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queued []Entry
+	snapshot.EachQueued(func(entry Entry) error {
+		queued = append(queued, entry)
+		return nil
+	})
+
+	restored := NewFrontier(false, NewBloomSet(1000, 0.01))
+	if err := restored.RestoreVisited(nil, filter); err != nil {
+		t.Fatal(err)
+	}
+	restored.RestoreQueued(queued)
+
+	if stats := restored.Stats(); stats.Visited != 1 || stats.Queued != 9 {
+		t.Errorf("got %d visited and %d queued, expected 1 and 9", stats.Visited, stats.Queued)
+	}
+	if restored.AddUrl(popped[0], 1) {
+		t.Error("the URL that was done was added again")
+	}
+	for i := 0; i < 9; i++ {
+		entry, err := restored.PopUrl(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored.Done(entry.Url)
+	}
+	if visited := restored.Stats().Visited; visited != 10 {
+		t.Errorf("got %d visited after popping everything, expected 10", visited)
+	}
+}
+
+func TestFrontierRestoreFalsePositive(t *testing.T) {
+	// A small filter with a few URLs in it has plenty of false positives.
+	f := NewFrontier(false, NewBloomSet(10, 0.5))
+	for i := 0; i < 20; i++ {
+		f.store.Visit(testUrl(i))
+	}
+	visited := f.Stats().Visited
+
+	falsePositive := ""
+	for i := 20; i < 1000 && falsePositive == ""; i++ {
+		if f.store.Visited(testUrl(i)) {
+			falsePositive = testUrl(i)
+		}
+	}
+	if falsePositive == "" {
+		t.Fatal("couldn't find a false positive")
+	}
+
+	f.RestoreQueued([]Entry{{Url: falsePositive}})
+	if got := f.Stats().Visited; got != visited {
+		t.Errorf("restoring a false positive changed the visited count from %d to %d", visited, got)
+	}
+}
+
+func BenchmarkFrontierAddPop(b *testing.B) {
+	for _, name := range []string{"memory", "disk"} {
+		b.Run(name, func(b *testing.B) {
+			f := frontiers[name](b)
+			defer f.Close()
+			ctx := context.Background()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				f.AddUrl(testUrl(i), 1)
+			}
+			for i := 0; i < b.N; i++ {
+				entry, err := f.PopUrl(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				f.Done(entry.Url)
+			}
+		})
+	}
+}
+
+func BenchmarkFrontierSnapshot(b *testing.B) {
+	for _, name := range []string{"memory", "disk"} {
+		b.Run(name, func(b *testing.B) {
+			f := frontiers[name](b)
+			defer f.Close()
+			ctx := context.Background()
+			for i := 0; i < 20000; i++ {
+				f.AddUrl(testUrl(i), 1)
+			}
+			for i := 0; i < 10000; i++ {
+				entry, err := f.PopUrl(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				f.Done(entry.Url)
+			}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				snapshot, err := f.Snapshot()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := snapshot.EachVisited(func(string) error { return nil }); err != nil {
+					b.Fatal(err)
+				}
+				if err := snapshot.EachQueued(func(Entry) error { return nil }); err != nil {
+					b.Fatal(err)
+				}
+				snapshot.Close()
+			}
+		})
+	}
+}
+
+func TestPopUrlSkipsHostsNotReady(t *testing.T) {
+	for name, newFrontier := range frontiers {
+		t.Run(name, func(t *testing.T) {
+			s := NewScheduler(0, 0)
+			s.Feedback("slow.example.com", 429, "60")
+
+			f := newFrontier(t)
+			defer f.Close()
+			f.SetScheduler(s)
+			f.AddUrl("https://slow.example.com", 0)
+			f.AddUrl("https://fast.example.com", 0)
+
+			entry, err := f.PopUrl(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Url != "https://fast.example.com" {
+				t.Fatalf("got %s, expected the host that's ready", entry.Url)
+			}
+			f.Done(entry.Url)
+
+			// The slow host is still queued, so the frontier isn't exhausted.
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+			defer cancel()
+			if entry, err := f.PopUrl(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("got %v %v, expected to wait for the slow host", entry, err)
+			}
+			if stats := f.Stats(); stats.Queued != 1 {
+				t.Errorf("got %d queued, expected the slow host", stats.Queued)
+			}
+
+			snapshot, err := f.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer snapshot.Close()
+			var queued []string
+			snapshot.EachQueued(func(entry Entry) error {
+				queued = append(queued, entry.Url)
+				return nil
+			})
+			if len(queued) != 1 || queued[0] != "https://slow.example.com" {
+				t.Errorf("got snapshot queue %v, expected the slow host", queued)
+			}
+		})
+	}
+}
+
+func TestPopUrlWaitsForDelayedHost(t *testing.T) {
+	s := NewScheduler(time.Millisecond*100, 0)
+	if err := s.Wait(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFrontier(false, NewSet())
+	f.SetScheduler(s)
+	f.AddUrl("https://example.com", 0)
+
+	start := time.Now()
+	entry, err := f.PopUrl(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < time.Millisecond*50 {
+		t.Errorf("got %s after %s, expected to wait for the host delay", entry.Url, waited)
+	}
+}
+
+func TestDiskStoreMemoryLimit(t *testing.T) {
+	const memoryLimit = 10
+	s, err := newDiskStore(filepath.Join(t.TempDir(), "frontier.db"), memoryLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	checkMemory := func() {
+		t.Helper()
+		if inMemory := len(s.head) + len(s.tail); inMemory > memoryLimit {
+			t.Fatalf("%d entries in memory, expected at most %d", inMemory, memoryLimit)
+		}
+	}
+
+	next := 0
+	for i := 0; i < 100; i++ {
+		s.Push(Entry{Url: testUrl(i)})
+		checkMemory()
+		// Pop every so often so head is refilled from disk while tail is full.
+		if i%3 == 0 {
+			entry, ok := s.Pop(false)
+			if !ok || entry.Url != testUrl(next) {
+				t.Fatalf("popped %+v, expected %s", entry, testUrl(next))
+			}
+			next++
+			checkMemory()
+		}
+	}
+	for ; next < 100; next++ {
+		entry, ok := s.Pop(false)
+		if !ok || entry.Url != testUrl(next) {
+			t.Fatalf("popped %+v, expected %s", entry, testUrl(next))
+		}
+		checkMemory()
+	}
+}
@@ -0,0 +1,244 @@
+package frontier
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// minBackoff is the first backoff used when a host asks us to slow down without
+	// saying for how long.
+	minBackoff = time.Second * 5
+	// maxBackoff caps how long a single host or IP can be backed off for.
+	maxBackoff = time.Minute * 5
+	// pruneInterval is how often state for hosts and IPs that haven't been requested
+	// for longer than maxBackoff is removed.
+	pruneInterval = time.Minute
+)
+
+// Scheduler enforces a minimum delay between requests to the same host and to the same
+// resolved IP, so hosts sharing a server or CDN aren't hit all at once. It backs off a
+// host and its IPs when asked to (429 or 503), honouring Retry-After. Scheduler is
+// thread-safe and should be held as a pointer.
+type Scheduler struct {
+	hostDelay time.Duration
+	ipDelay   time.Duration
+	resolver  *net.Resolver
+	// mu protects everything below.
+	mu *sync.Mutex
+	// next is the earliest time the next request can be made, keyed by hostKey or ipKey.
+	next map[string]time.Time
+	// backoff is the extra delay currently added for a key.
+	backoff map[string]time.Duration
+	// hostDelays overrides hostDelay for specific hosts, e.g. from robots.txt.
+	hostDelays map[string]time.Duration
+	ips        map[string][]string
+	lastPrune  time.Time
+}
+
+// NewScheduler creates a new Scheduler. A delay of 0 disables that limit.
+func NewScheduler(hostDelay, ipDelay time.Duration) *Scheduler {
+	return &Scheduler{
+		hostDelay:  hostDelay,
+		ipDelay:    ipDelay,
+		resolver:   net.DefaultResolver,
+		mu:         &sync.Mutex{},
+		next:       make(map[string]time.Time),
+		backoff:    make(map[string]time.Duration),
+		hostDelays: make(map[string]time.Duration),
+		ips:        make(map[string][]string),
+		lastPrune:  time.Now(),
+	}
+}
+
+func hostKey(hostname string) string { return "host\t" + hostname }
+func ipKey(ip string) string         { return "ip\t" + ip }
+
+// keys returns the keys for hostname and each IP it resolves to, resolving it if it
+// hasn't been seen before. If resolving fails only the host key is returned.
+func (s *Scheduler) keys(ctx context.Context, hostname string) []string {
+	s.mu.Lock()
+	ips, ok := s.ips[hostname]
+	s.mu.Unlock()
+
+	if !ok && s.ipDelay > 0 {
+		addrs, err := s.resolver.LookupIPAddr(ctx, hostname)
+		if err == nil {
+			for _, addr := range addrs {
+				ips = append(ips, addr.IP.String())
+			}
+		}
+		s.mu.Lock()
+		s.ips[hostname] = ips
+		s.mu.Unlock()
+	}
+
+	keys := []string{hostKey(hostname)}
+	for _, ip := range ips {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
+
+// delay returns the delay needed after a request for key, s.mu must be held.
+func (s *Scheduler) delay(key, hostname string) time.Duration {
+	d := s.ipDelay
+	if key == hostKey(hostname) {
+		d = max(s.hostDelay, s.hostDelays[hostname])
+	}
+	return d + s.backoff[key]
+}
+
+// Wait blocks until a request can be made to hostname and reserves that slot, so
+// concurrent callers for the same host or IP queue up behind each other.
+func (s *Scheduler) Wait(ctx context.Context, hostname string) error {
+	keys := s.keys(ctx, hostname)
+
+	s.mu.Lock()
+	at := time.Now()
+	s.prune(at)
+	for _, key := range keys {
+		if next := s.next[key]; next.After(at) {
+			at = next
+		}
+	}
+	for _, key := range keys {
+		s.next[key] = at.Add(s.delay(key, hostname))
+	}
+	s.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ReadyAt returns the earliest time a request can be made to hostname without waiting,
+// which is in the past if it can be made now. Only IPs hostname has already been
+// resolved to are taken in to account.
+func (s *Scheduler) ReadyAt(hostname string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	at := s.next[hostKey(hostname)]
+	for _, ip := range s.ips[hostname] {
+		if next := s.next[ipKey(ip)]; next.After(at) {
+			at = next
+		}
+	}
+	return at
+}
+
+// prune removes the state for hosts and IPs that haven't been requested for longer than
+// maxBackoff, at most once every pruneInterval, s.mu must be held. Any backoff they had
+// has expired by then, and they'd be treated the same as a host that's never been seen.
+func (s *Scheduler) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < pruneInterval {
+		return
+	}
+	s.lastPrune = now
+
+	expired := now.Add(-maxBackoff)
+	for key, next := range s.next {
+		if next.Before(expired) {
+			delete(s.next, key)
+			delete(s.backoff, key)
+		}
+	}
+	for key := range s.backoff {
+		if _, ok := s.next[key]; !ok {
+			delete(s.backoff, key)
+		}
+	}
+	for hostname := range s.ips {
+		if _, ok := s.next[hostKey(hostname)]; !ok {
+			delete(s.ips, hostname)
+		}
+	}
+	for hostname := range s.hostDelays {
+		if _, ok := s.next[hostKey(hostname)]; !ok {
+			delete(s.hostDelays, hostname)
+		}
+	}
+}
+
+// SetHostDelay sets the minimum delay for hostname if it's longer than the default,
+// e.g. from a robots.txt Crawl-delay. A slot already reserved for hostname is pushed back
+// so the new delay also applies to the next request.
+func (s *Scheduler) SetHostDelay(hostname string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := hostKey(hostname)
+	previous := s.delay(key, hostname)
+	s.hostDelays[hostname] = min(delay, maxBackoff)
+
+	if next, ok := s.next[key]; ok {
+		if extra := s.delay(key, hostname) - previous; extra > 0 {
+			s.next[key] = next.Add(extra)
+		}
+	}
+}
+
+// Feedback adjusts the backoff for hostname and its IPs from a response. 429 and 503
+// responses increase the backoff (using Retry-After if given, up to maxBackoff),
+// anything else reduces it.
+// It returns true if the host asked us to slow down.
+func (s *Scheduler) Feedback(hostname string, statusCode int, retryAfter string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []string{hostKey(hostname)}
+	for _, ip := range s.ips[hostname] {
+		keys = append(keys, ipKey(ip))
+	}
+
+	slowDown := statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+	requested := parseRetryAfter(retryAfter)
+
+	for _, key := range keys {
+		current := s.backoff[key]
+		if !slowDown {
+			// Recover gradually, a single success doesn't mean the limit has gone.
+			if current /= 2; current < time.Second {
+				delete(s.backoff, key)
+			} else {
+				s.backoff[key] = current
+			}
+			continue
+		}
+
+		current = max(current*2, minBackoff, requested)
+		current = min(current, maxBackoff)
+		s.backoff[key] = current
+
+		if next := time.Now().Add(current); next.After(s.next[key]) {
+			s.next[key] = next
+		}
+	}
+
+	return slowDown
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either a number of
+// seconds or a HTTP date. It returns 0 if the value is missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
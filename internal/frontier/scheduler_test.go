@@ -0,0 +1,93 @@
+package frontier
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSchedulerSetHostDelayAppliesToReservedSlot(t *testing.T) {
+	s := NewScheduler(0, 0)
+	ctx := context.Background()
+
+	if err := s.Wait(ctx, "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	s.SetHostDelay("example.com", time.Millisecond*200)
+
+	start := time.Now()
+	if err := s.Wait(ctx, "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < time.Millisecond*150 {
+		t.Fatalf("second Wait returned after %s, want the 200ms host delay", waited)
+	}
+}
+
+func TestSchedulerSetHostDelayOtherHost(t *testing.T) {
+	s := NewScheduler(0, 0)
+	ctx := context.Background()
+
+	s.SetHostDelay("example.com", time.Second)
+
+	start := time.Now()
+	if err := s.Wait(ctx, "example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited > time.Millisecond*100 {
+		t.Fatalf("Wait for another host returned after %s, want no delay", waited)
+	}
+}
+
+func TestSchedulerPrune(t *testing.T) {
+	s := NewScheduler(0, time.Second)
+	s.ips["old.example"] = []string{"192.0.2.1"}
+	s.ips["new.example"] = []string{"192.0.2.2"}
+	s.SetHostDelay("old.example", time.Second)
+	s.SetHostDelay("new.example", time.Second)
+
+	now := time.Now()
+	long := now.Add(-maxBackoff * 2)
+	s.next[hostKey("old.example")] = long
+	s.next[ipKey("192.0.2.1")] = long
+	s.backoff[ipKey("192.0.2.1")] = minBackoff
+	s.next[hostKey("new.example")] = now
+	s.next[ipKey("192.0.2.2")] = now
+	s.backoff[ipKey("192.0.2.2")] = minBackoff
+
+	// It only prunes once every pruneInterval.
+	s.prune(now)
+	if len(s.next) != 4 {
+		t.Fatalf("pruned %d keys before pruneInterval", 4-len(s.next))
+	}
+
+	s.prune(now.Add(pruneInterval))
+	if _, ok := s.next[hostKey("old.example")]; ok {
+		t.Error("expected the old host to be pruned")
+	}
+	if _, ok := s.backoff[ipKey("192.0.2.1")]; ok {
+		t.Error("expected the old IP's backoff to be pruned")
+	}
+	if _, ok := s.ips["old.example"]; ok {
+		t.Error("expected the old host's IPs to be pruned")
+	}
+	if _, ok := s.hostDelays["old.example"]; ok {
+		t.Error("expected the old host's delay to be pruned")
+	}
+	if len(s.next) != 2 || len(s.backoff) != 1 || len(s.ips) != 1 || len(s.hostDelays) != 1 {
+		t.Errorf("expected the new host to be kept, got %v %v %v %v", s.next, s.backoff, s.ips, s.hostDelays)
+	}
+}
+
+func TestSchedulerReadyAt(t *testing.T) {
+	s := NewScheduler(time.Minute, 0)
+	if at := s.ReadyAt("example.com"); at.After(time.Now()) {
+		t.Fatalf("got %s, expected an unseen host to be ready", at)
+	}
+	if err := s.Wait(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if at := s.ReadyAt("example.com"); time.Until(at) < time.Second*50 {
+		t.Errorf("got %s, expected the host delay", at)
+	}
+}
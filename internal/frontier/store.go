@@ -0,0 +1,206 @@
+package frontier
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+
+	. "github.com/psidex/nomad/internal/lib"
+)
+
+// VisitedSet is the set of visited URLs used by the in-memory frontier, it is
+// implemented exactly by lib.Set and probabilistically by lib.BloomSet.
+type VisitedSet interface {
+	Add(url string)
+	Remove(url string)
+	Contains(url string) bool
+	Size() int
+}
+
+// ListableSet is a VisitedSet whose contents can be listed.
+type ListableSet interface {
+	VisitedSet
+	AsSlice() []string
+}
+
+// FilterSet is a probabilistic VisitedSet which can't be listed, but can be serialized
+// and can estimate its error.
+type FilterSet interface {
+	VisitedSet
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	FalsePositiveRate() float64
+}
+
+var (
+	_ ListableSet = Set{}
+	_ FilterSet   = (*BloomSet)(nil)
+)
+
+// Store holds the queued entries and visited URLs for a Frontier. The Frontier
+// serialises access to its Store, so implementations don't have to be thread-safe.
+type Store interface {
+	// Push adds an entry to the back of the queue.
+	Push(entry Entry)
+	// Pop removes an entry from the front of the queue, or a random entry if random is
+	// true. ok is false if the queue is empty.
+	Pop(random bool) (entry Entry, ok bool)
+	// Len returns the number of queued entries.
+	Len() int
+
+	Visit(url string)
+	Unvisit(url string)
+	Visited(url string) bool
+	// VisitedCount returns the number of visited URLs.
+	VisitedCount() int
+	// FalsePositiveRate returns the estimated chance of Visited returning true for a URL
+	// that wasn't visited, which is 0 if the store is exact.
+	FalsePositiveRate() float64
+	// LoadVisited adds visited URLs, or replaces the visited set with a serialized
+	// filter, from a StoreSnapshot. It may be called more than once.
+	LoadVisited(urls []string, filter []byte) error
+
+	// Snapshot returns the queued entries and visited URLs as they are now. It stays
+	// the same while the store keeps being used, and must be closed.
+	Snapshot() (StoreSnapshot, error)
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// StoreSnapshot is the state of a Store at the time Store.Snapshot was called. It can be
+// read without holding the Frontier's lock, so it doesn't stop the crawl while it's
+// saved.
+type StoreSnapshot interface {
+	// EachEntry calls fn with every queued entry in order, stopping at the first error.
+	EachEntry(fn func(Entry) error) error
+	// EachVisited calls fn with every visited URL, stopping at the first error. It does
+	// nothing if they can't be listed.
+	EachVisited(fn func(url string) error) error
+	// VisitedFilter returns a copy of the visited filter if the visited URLs can't be
+	// listed, which isn't serialized until it's needed.
+	VisitedFilter() FilterSet
+	Close() error
+}
+
+// memoryStore is a Store that keeps everything in memory.
+type memoryStore struct {
+	queue   *Queue[Entry]
+	visited VisitedSet
+}
+
+var _ Store = (*memoryStore)(nil)
+
+func newMemoryStore(visited VisitedSet) *memoryStore {
+	return &memoryStore{
+		queue:   NewQueue[Entry](),
+		visited: visited,
+	}
+}
+
+func (s *memoryStore) Push(entry Entry) {
+	s.queue.Enqueue(entry)
+}
+
+func (s *memoryStore) Pop(random bool) (Entry, bool) {
+	if random {
+		return s.queue.RandomDequeue()
+	}
+	return s.queue.Dequeue()
+}
+
+func (s *memoryStore) Len() int {
+	return s.queue.Size()
+}
+
+func (s *memoryStore) Visit(url string) {
+	s.visited.Add(url)
+}
+
+func (s *memoryStore) Unvisit(url string) {
+	s.visited.Remove(url)
+}
+
+func (s *memoryStore) Visited(url string) bool {
+	return s.visited.Contains(url)
+}
+
+func (s *memoryStore) VisitedCount() int {
+	return s.visited.Size()
+}
+
+func (s *memoryStore) FalsePositiveRate() float64 {
+	if filter, ok := s.visited.(FilterSet); ok {
+		return filter.FalsePositiveRate()
+	}
+	return 0
+}
+
+// Snapshot copies the queue and visited set, as they're already in memory this is no
+// worse than what the store itself needs.
+func (s *memoryStore) Snapshot() (StoreSnapshot, error) {
+	snapshot := &memorySnapshot{entries: s.queue.Items()}
+	switch visited := s.visited.(type) {
+	case ListableSet:
+		snapshot.visited = visited.AsSlice()
+	case *BloomSet:
+		// Serializing the filter is left until the snapshot is saved, as it's slow and the
+		// Frontier's lock is held here.
+		snapshot.filter = visited.Clone()
+	default:
+		return nil, fmt.Errorf("can't save visited set of type %T", s.visited)
+	}
+	return snapshot, nil
+}
+
+func (s *memoryStore) LoadVisited(urls []string, filter []byte) error {
+	if filter != nil {
+		visited, ok := s.visited.(FilterSet)
+		if !ok {
+			return errors.New("can't load a filter in to an exact visited set")
+		}
+		if err := visited.UnmarshalBinary(filter); err != nil {
+			return err
+		}
+	}
+	for _, url := range urls {
+		s.visited.Add(url)
+	}
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// memorySnapshot is a memoryStore's StoreSnapshot.
+type memorySnapshot struct {
+	entries []Entry
+	visited []string
+	filter  FilterSet
+}
+
+func (s *memorySnapshot) EachEntry(fn func(Entry) error) error {
+	for _, entry := range s.entries {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memorySnapshot) EachVisited(fn func(url string) error) error {
+	for _, url := range s.visited {
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memorySnapshot) VisitedFilter() FilterSet {
+	return s.filter
+}
+
+func (s *memorySnapshot) Close() error {
+	return nil
+}
@@ -0,0 +1,50 @@
+// Package assets embeds the JavaScript libraries used by the HTML graph providers, so
+// that their output can be opened without network access.
+package assets
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//go:generate go run fetch.go
+
+// These are the names of the embedded assets, fetch.go downloads them in to js/.
+const (
+	VisNetwork = "vis-network.min.js"
+	ECharts    = "echarts.min.js"
+)
+
+//go:embed js
+var files embed.FS
+
+// Check returns an error if any of the named assets haven't been embedded, or are empty.
+func Check(names ...string) error {
+	for _, name := range names {
+		data, err := files.ReadFile("js/" + name)
+		if err != nil {
+			return notEmbedded(name, err)
+		}
+		if len(data) == 0 {
+			return notEmbedded(name, errors.New("the file is empty"))
+		}
+	}
+	return nil
+}
+
+func notEmbedded(name string, err error) error {
+	return fmt.Errorf("%s is not embedded, run `go generate ./internal/graphs/assets` and rebuild: %w", name, err)
+}
+
+// InlineScript returns the named asset inside a <script> tag.
+func InlineScript(name string) (string, error) {
+	data, err := files.ReadFile("js/" + name)
+	if err != nil {
+		return "", notEmbedded(name, err)
+	}
+	// A "</script" anywhere in the JS would end the tag early.
+	script := strings.ReplaceAll(string(data), "</script", `<\/script`)
+	return "<script type=\"text/javascript\">\n" + script + "\n</script>", nil
+}
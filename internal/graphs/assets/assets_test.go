@@ -0,0 +1,36 @@
+package assets
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEmbedded(t *testing.T) {
+	if err := Check(VisNetwork, ECharts); err != nil {
+		if os.Getenv("NOMAD_TEST_ASSETS") == "" {
+			t.Skipf("set NOMAD_TEST_ASSETS to require the assets: %v", err)
+		}
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{VisNetwork, ECharts} {
+		script, err := InlineScript(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := strings.TrimSuffix(strings.TrimPrefix(script, "<script type=\"text/javascript\">\n"), "\n</script>")
+		if strings.TrimSpace(body) == "" {
+			t.Errorf("%s is empty", name)
+		}
+		if strings.Contains(body, "</script") {
+			t.Errorf("%s contains an unescaped </script", name)
+		}
+	}
+}
+
+func TestCheckMissing(t *testing.T) {
+	if err := Check("missing.min.js"); err == nil {
+		t.Error("expected an error for an asset that isn't embedded")
+	}
+}
@@ -0,0 +1,58 @@
+//go:build ignore
+
+// This downloads the pinned versions of the embedded assets and their licences in to js/.
+// The Docker build and CI run it before building.
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+var sources = map[string]string{
+	"vis-network.min.js": "https://unpkg.com/vis-network@9.1.9/standalone/umd/vis-network.min.js",
+	// vis-network is dual licensed, this is the licence it's distributed under here.
+	"vis-network.LICENSE": "https://unpkg.com/vis-network@9.1.9/LICENSE-APACHE-2.0",
+	// The same major version go-echarts loads from its own assets host.
+	"echarts.min.js":  "https://unpkg.com/echarts@5.4.3/dist/echarts.min.js",
+	"echarts.LICENSE": "https://unpkg.com/echarts@5.4.3/LICENSE",
+}
+
+func fetch(name, url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+
+	f, err := os.Create(filepath.Join("js", name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, resp.Body)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%s is empty", url)
+	}
+	return f.Close()
+}
+
+func main() {
+	for name, url := range sources {
+		log.Printf("Fetching %s from %s\n", name, url)
+		if err := fetch(name, url); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
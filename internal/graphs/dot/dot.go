@@ -0,0 +1,251 @@
+package dot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// Cluster decides how hostnames are grouped in to subgraphs.
+type Cluster string
+
+const (
+	ClusterNone   Cluster = ""
+	ClusterDomain Cluster = "domain" // By registrable domain, e.g. www.bbc.co.uk -> bbc.co.uk
+	ClusterTLD    Cluster = "tld"    // By public suffix, e.g. www.bbc.co.uk -> co.uk
+)
+
+// Options configures the DOT output.
+type Options struct {
+	Directed bool    `json:"directed"`
+	Cluster  Cluster `json:"cluster"`
+	Degree   bool    `json:"degree"`   // Adds indegree and outdegree attributes to each node.
+	DeadEnds bool    `json:"deadEnds"` // Highlights hostnames that were crawled but linked nowhere.
+}
+
+func (o Options) Validate() error {
+	switch o.Cluster {
+	case ClusterNone, ClusterDomain, ClusterTLD:
+		return nil
+	default:
+		return fmt.Errorf("unknown DOT cluster: %s, must be %s or %s", o.Cluster, ClusterDomain, ClusterTLD)
+	}
+}
+
+type node struct {
+	inDegree  int
+	outDegree int
+	deadEnd   bool
+}
+
+type edge struct {
+	from, to string
+	kind     graphs.LinkKind
+}
+
+// Dot defines a CliGraphProvider that renders a Graphviz DOT file. There's an edge for
+// each kind of link between two hostnames, edges to resources are dashed.
+type Dot struct {
+	options Options
+	mu      *sync.Mutex
+	nodes   map[string]*node
+	edges   map[edge]struct{}
+	// pairs is the connected hostnames regardless of kind, for the degree.
+	pairs map[[2]string]struct{}
+}
+
+var (
+	_ graphs.CliGraphProvider = (*Dot)(nil)
+	_ graphs.CrawlNotifier    = (*Dot)(nil)
+)
+
+func NewDot(options Options) *Dot {
+	return &Dot{
+		options: options,
+		mu:      &sync.Mutex{},
+		nodes:   make(map[string]*node),
+		edges:   make(map[edge]struct{}),
+		pairs:   make(map[[2]string]struct{}),
+	}
+}
+
+func (d *Dot) getNode(hostname string) *node {
+	n, ok := d.nodes[hostname]
+	if !ok {
+		n = &node{}
+		d.nodes[hostname] = n
+	}
+	return n
+}
+
+func (d *Dot) AddHostnameConnection(connection graphs.Edge) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	fromHost, toHost, kind := connection.From, connection.To, connection.Kind
+
+	from := d.getNode(fromHost)
+	to := d.getNode(toHost)
+
+	if fromHost == toHost {
+		return
+	}
+
+	e := edge{from: fromHost, to: toHost, kind: kind}
+	if _, ok := d.edges[e]; ok {
+		return
+	}
+	if !d.options.Directed {
+		if _, ok := d.edges[edge{from: toHost, to: fromHost, kind: kind}]; ok {
+			return
+		}
+	}
+	d.edges[e] = struct{}{}
+
+	pair := [2]string{fromHost, toHost}
+	if _, ok := d.pairs[pair]; ok {
+		return
+	}
+	if !d.options.Directed {
+		if _, ok := d.pairs[[2]string{toHost, fromHost}]; ok {
+			return
+		}
+	}
+	d.pairs[pair] = struct{}{}
+	from.outDegree++
+	to.inDegree++
+}
+
+func (d *Dot) NotifyStartCrawl(workerId uint, hostname string) {}
+
+func (d *Dot) NotifyEndCrawl(workerId uint, hostname string, deadEnd bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.getNode(hostname).deadEnd = deadEnd
+}
+
+// quote returns s as a quoted DOT ID.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// clusterOf returns the name of the cluster hostname belongs to, IP addresses and
+// hostnames without a known suffix are put in a cluster by themselves.
+func (d *Dot) clusterOf(hostname string) string {
+	if net.ParseIP(hostname) != nil {
+		return hostname
+	}
+	switch d.options.Cluster {
+	case ClusterDomain:
+		if domain, err := publicsuffix.EffectiveTLDPlusOne(hostname); err == nil {
+			return domain
+		}
+	case ClusterTLD:
+		if suffix, _ := publicsuffix.PublicSuffix(hostname); suffix != "" {
+			return suffix
+		}
+	}
+	return hostname
+}
+
+func (d *Dot) nodeStatement(hostname string, n node) string {
+	attributes := []string{}
+	if d.options.Degree {
+		attributes = append(attributes,
+			fmt.Sprintf("indegree=%d", n.inDegree),
+			fmt.Sprintf("outdegree=%d", n.outDegree),
+			"tooltip="+quote(fmt.Sprintf("in: %d, out: %d", n.inDegree, n.outDegree)),
+		)
+	}
+	if d.options.DeadEnds && n.deadEnd {
+		attributes = append(attributes, "deadend=true", "style=filled", `fillcolor="#f4cccc"`)
+	}
+	if len(attributes) == 0 {
+		return quote(hostname) + ";"
+	}
+	return quote(hostname) + " [" + strings.Join(attributes, ", ") + "];"
+}
+
+func (d *Dot) Extension() string {
+	return ".dot"
+}
+
+func (d *Dot) Render(w io.Writer) error {
+	// Copy everything so the lock isn't held while writing.
+	d.mu.Lock()
+	nodes := make(map[string]node, len(d.nodes))
+	hostnames := make([]string, 0, len(d.nodes))
+	for hostname, n := range d.nodes {
+		nodes[hostname] = *n
+		hostnames = append(hostnames, hostname)
+	}
+	edges := make([]edge, 0, len(d.edges))
+	for e := range d.edges {
+		edges = append(edges, e)
+	}
+	d.mu.Unlock()
+
+	sort.Strings(hostnames)
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].from != edges[j].from {
+			return edges[i].from < edges[j].from
+		}
+		if edges[i].to != edges[j].to {
+			return edges[i].to < edges[j].to
+		}
+		return edges[i].kind < edges[j].kind
+	})
+
+	graphType, edgeOp := "graph", "--"
+	if d.options.Directed {
+		graphType, edgeOp = "digraph", "->"
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s nomad {\n", graphType)
+
+	if d.options.Cluster == ClusterNone {
+		for _, hostname := range hostnames {
+			fmt.Fprintf(bw, "  %s\n", d.nodeStatement(hostname, nodes[hostname]))
+		}
+	} else {
+		clusters := make(map[string][]string)
+		names := []string{}
+		for _, hostname := range hostnames {
+			name := d.clusterOf(hostname)
+			if _, ok := clusters[name]; !ok {
+				names = append(names, name)
+			}
+			clusters[name] = append(clusters[name], hostname)
+		}
+		sort.Strings(names)
+
+		for i, name := range names {
+			// Graphviz only draws subgraphs as clusters if their name starts with "cluster".
+			fmt.Fprintf(bw, "  subgraph cluster_%d {\n    label=%s;\n", i, quote(name))
+			for _, hostname := range clusters[name] {
+				fmt.Fprintf(bw, "    %s\n", d.nodeStatement(hostname, nodes[hostname]))
+			}
+			fmt.Fprintf(bw, "  }\n")
+		}
+	}
+
+	for _, e := range edges {
+		style := ""
+		if e.kind.IsResource() {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(bw, "  %s %s %s [kind=%s%s];\n", quote(e.from), edgeOp, quote(e.to), quote(string(e.kind)), style)
+	}
+
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
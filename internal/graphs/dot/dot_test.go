@@ -0,0 +1,165 @@
+package dot
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+func render(t *testing.T, d *Dot) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := d.Render(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestQuote(t *testing.T) {
+	for _, test := range []struct {
+		s, expected string
+	}{
+		{"a.com", `"a.com"`},
+		{`a"b.com`, `"a\"b.com"`},
+		{`a\b.com`, `"a\\b.com"`},
+		{`a\"b`, `"a\\\"b"`},
+		{"a\nb", `"a\nb"`},
+	} {
+		if got := quote(test.s); got != test.expected {
+			t.Errorf("quote(%q) is %s, expected %s", test.s, got, test.expected)
+		}
+	}
+}
+
+func TestRenderQuotesHostnames(t *testing.T) {
+	d := NewDot(Options{Directed: true})
+	d.AddHostnameConnection(graphs.Edge{From: `a"b.com`, To: `c\d.com`, Kind: graphs.LinkAnchor})
+
+	expected := `digraph nomad {
+  "a\"b.com";
+  "c\\d.com";
+  "a\"b.com" -> "c\\d.com" [kind="a"];
+}
+`
+	if got := render(t, d); got != expected {
+		t.Errorf("got\n%s\nexpected\n%s", got, expected)
+	}
+}
+
+func TestAddHostnameConnection(t *testing.T) {
+	connections := []graphs.Edge{
+		{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor},
+		{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor},
+		{From: "b.com", To: "a.com", Kind: graphs.LinkAnchor},
+		{From: "b.com", To: "a.com", Kind: graphs.LinkImage},
+		{From: "a.com", To: "a.com", Kind: graphs.LinkAnchor},
+	}
+
+	for _, test := range []struct {
+		directed bool
+		expected string
+	}{
+		// b.com to a.com is the same edge as a.com to b.com, unless it's another kind. The
+		// degree counts the pair of hostnames once.
+		{false, `graph nomad {
+  "a.com" [indegree=0, outdegree=1, tooltip="in: 0, out: 1"];
+  "b.com" [indegree=1, outdegree=0, tooltip="in: 1, out: 0"];
+  "a.com" -- "b.com" [kind="a"];
+  "b.com" -- "a.com" [kind="img", style=dashed];
+}
+`},
+		{true, `digraph nomad {
+  "a.com" [indegree=1, outdegree=1, tooltip="in: 1, out: 1"];
+  "b.com" [indegree=1, outdegree=1, tooltip="in: 1, out: 1"];
+  "a.com" -> "b.com" [kind="a"];
+  "b.com" -> "a.com" [kind="a"];
+  "b.com" -> "a.com" [kind="img", style=dashed];
+}
+`},
+	} {
+		d := NewDot(Options{Directed: test.directed, Degree: true})
+		for _, connection := range connections {
+			d.AddHostnameConnection(connection)
+		}
+		if got := render(t, d); got != test.expected {
+			t.Errorf("directed %t: got\n%s\nexpected\n%s", test.directed, got, test.expected)
+		}
+	}
+}
+
+func TestClusterOf(t *testing.T) {
+	for _, test := range []struct {
+		cluster  Cluster
+		hostname string
+		expected string
+	}{
+		{ClusterDomain, "www.bbc.co.uk", "bbc.co.uk"},
+		{ClusterDomain, "bbc.co.uk", "bbc.co.uk"},
+		{ClusterDomain, "a.b.example.com", "example.com"},
+		{ClusterDomain, "co.uk", "co.uk"},
+		{ClusterTLD, "www.bbc.co.uk", "co.uk"},
+		{ClusterTLD, "example.com", "com"},
+		{ClusterDomain, "127.0.0.1", "127.0.0.1"},
+		{ClusterTLD, "127.0.0.1", "127.0.0.1"},
+		{ClusterTLD, "::1", "::1"},
+		{ClusterNone, "www.bbc.co.uk", "www.bbc.co.uk"},
+	} {
+		d := NewDot(Options{Cluster: test.cluster})
+		if got := d.clusterOf(test.hostname); got != test.expected {
+			t.Errorf("%q cluster of %s is %s, expected %s", test.cluster, test.hostname, got, test.expected)
+		}
+	}
+}
+
+func TestRenderClusters(t *testing.T) {
+	d := NewDot(Options{Directed: true, Cluster: ClusterDomain})
+	d.AddHostnameConnection(graphs.Edge{From: "www.example.com", To: "cdn.example.com", Kind: graphs.LinkScript})
+	d.AddHostnameConnection(graphs.Edge{From: "www.example.com", To: "10.0.0.1", Kind: graphs.LinkAnchor})
+
+	expected := `digraph nomad {
+  subgraph cluster_0 {
+    label="10.0.0.1";
+    "10.0.0.1";
+  }
+  subgraph cluster_1 {
+    label="example.com";
+    "cdn.example.com";
+    "www.example.com";
+  }
+  "www.example.com" -> "10.0.0.1" [kind="a"];
+  "www.example.com" -> "cdn.example.com" [kind="script", style=dashed];
+}
+`
+	if got := render(t, d); got != expected {
+		t.Errorf("got\n%s\nexpected\n%s", got, expected)
+	}
+}
+
+func TestRenderDeadEnds(t *testing.T) {
+	for _, test := range []struct {
+		deadEnds bool
+		expected string
+	}{
+		{false, `digraph nomad {
+  "a.com";
+  "b.com";
+  "a.com" -> "b.com" [kind="a"];
+}
+`},
+		{true, `digraph nomad {
+  "a.com";
+  "b.com" [deadend=true, style=filled, fillcolor="#f4cccc"];
+  "a.com" -> "b.com" [kind="a"];
+}
+`},
+	} {
+		d := NewDot(Options{Directed: true, DeadEnds: test.deadEnds})
+		d.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor})
+		d.NotifyEndCrawl(1, "a.com", false)
+		d.NotifyEndCrawl(1, "b.com", true)
+		if got := render(t, d); got != test.expected {
+			t.Errorf("dead ends %t: got\n%s\nexpected\n%s", test.deadEnds, got, test.expected)
+		}
+	}
+}
@@ -1,41 +1,45 @@
 package graphs
 
 import (
+	"bytes"
 	"io"
-	"os"
+	"strings"
 	"sync"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/components"
 	"github.com/go-echarts/go-echarts/v2/opts"
 
+	"github.com/psidex/nomad/internal/graphs/assets"
 	. "github.com/psidex/nomad/internal/lib"
 )
 
 // ECharts defines a CliGraphProvider that renders a go-echarts HTML file.
 type ECharts struct {
+	offline   bool
 	mu        *sync.Mutex
 	hostnames Set
-	edges     Set
+	weights   *EdgeWeights
 	nodes     []opts.GraphNode
-	links     []opts.GraphLink
 }
 
 var _ CliGraphProvider = (*ECharts)(nil)
 
-func NewECharts() *ECharts {
+// NewECharts creates an ECharts, if offline is true the echarts JS is embedded in the
+// HTML instead of being loaded from a CDN. If collapseDirection is true links in either
+// direction between two hostnames are drawn as one edge, otherwise edges have arrows.
+func NewECharts(offline, collapseDirection bool) *ECharts {
 	return &ECharts{
+		offline:   offline,
 		mu:        &sync.Mutex{},
 		hostnames: NewSet(),
-		edges:     NewSet(),
+		weights:   NewEdgeWeights(collapseDirection),
 		nodes:     []opts.GraphNode{},
-		links:     []opts.GraphLink{},
 	}
 }
 
-func (e ECharts) getNewNodesAndEdges(fromHost, toHost string) ([]opts.GraphNode, []opts.GraphLink) {
+func (e ECharts) getNewNodes(fromHost, toHost string) []opts.GraphNode {
 	newNodes := []opts.GraphNode{}
-	newLinks := []opts.GraphLink{}
 
 	if !e.hostnames.Contains(fromHost) {
 		e.hostnames.Add(fromHost)
@@ -51,48 +55,75 @@ func (e ECharts) getNewNodesAndEdges(fromHost, toHost string) ([]opts.GraphNode,
 		})
 	}
 
-	edge := fromHost + "\t" + toHost
-	inverseEdge := toHost + "\t" + fromHost
-
-	if fromHost != toHost && !e.edges.Contains(edge) && !e.edges.Contains(inverseEdge) {
-		e.edges.Add(edge)
-		newLinks = append(newLinks, opts.GraphLink{
-			Source: fromHost,
-			Target: toHost,
-		})
-	}
-
-	return newNodes, newLinks
+	return newNodes
 }
 
-func (e *ECharts) AddHostnameConnection(fromHost, toHost string) {
+func (e *ECharts) AddHostnameConnection(edge Edge) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	newNodes, newLinks := e.getNewNodesAndEdges(fromHost, toHost)
-
-	e.nodes = append(e.nodes, newNodes...)
-	e.links = append(e.links, newLinks...)
+	e.nodes = append(e.nodes, e.getNewNodes(edge.From, edge.To)...)
+	e.weights.Add(edge)
 }
 
-func (e ECharts) RenderToFile(filename string) error {
-	filename = filename + ".html"
+func (e *ECharts) Extension() string {
+	return ".html"
+}
 
+func (e *ECharts) Render(w io.Writer) error {
+	// Render copies so the lock isn't held while rendering.
 	e.mu.Lock()
-	defer e.mu.Unlock()
+	nodes := append([]opts.GraphNode(nil), e.nodes...)
+	edges := e.weights.Edges()
+	e.mu.Unlock()
+
+	links := make([]opts.GraphLink, len(edges))
+	for i, edge := range edges {
+		links[i] = opts.GraphLink{
+			Source: edge.From,
+			Target: edge.To,
+			Value:  float32(edge.Weight),
+			LineStyle: &opts.LineStyle{
+				Color: edge.Kind.Color(),
+				Width: float32(EdgeSize(edge.Weight)),
+			},
+		}
+	}
 
 	page := components.NewPage()
-	page.AddCharts(graphBase(e.nodes, e.links))
+	page.AddCharts(graphBase(nodes, links, !e.weights.CollapseDirection()))
 
-	f, err := os.Create(filename)
+	if !e.offline {
+		return page.Render(w)
+	}
+
+	script, err := assets.InlineScript(assets.ECharts)
 	if err != nil {
 		return err
 	}
 
-	return page.Render(io.MultiWriter(f))
+	// Render without the CDN script and put the embedded one in its place.
+	page.ClearPresetJSAssets()
+	var buf bytes.Buffer
+	if err := page.Render(&buf); err != nil {
+		return err
+	}
+	rendered := strings.Replace(buf.String(), "</head>", script+"\n</head>", 1)
+
+	_, err = io.WriteString(w, rendered)
+	return err
 }
 
-func graphBase(nodes []opts.GraphNode, links []opts.GraphLink) *charts.Graph {
+func graphBase(nodes []opts.GraphNode, links []opts.GraphLink, directed bool) *charts.Graph {
+	chart := opts.GraphChart{
+		Draggable: opts.Bool(true),
+		Roam:      opts.Bool(true),
+		Force:     &opts.GraphForce{Repulsion: 400},
+	}
+	if directed {
+		chart.EdgeSymbol = []string{"none", "arrow"}
+	}
+
 	graph := charts.NewGraph()
 	graph.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{
@@ -111,13 +142,7 @@ func graphBase(nodes []opts.GraphNode, links []opts.GraphLink) *charts.Graph {
 		"graph",
 		nodes,
 		links,
-		charts.WithGraphChartOpts(
-			opts.GraphChart{
-				Draggable: opts.Bool(true),
-				Roam:      opts.Bool(true),
-				Force:     &opts.GraphForce{Repulsion: 400},
-			},
-		),
+		charts.WithGraphChartOpts(chart),
 		charts.WithLabelOpts(opts.Label{
 			Show:     opts.Bool(true),
 			Color:    "black",
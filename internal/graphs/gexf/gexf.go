@@ -0,0 +1,109 @@
+package gexf
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// dateTime is the xsd:dateTime format used for the dynamic start times.
+const dateTime = "2006-01-02T15:04:05.000Z07:00"
+
+// GEXF defines a CliGraphProvider that renders a GEXF file for Gephi. Each node and edge
+// has a start time so Gephi's timeline can replay the order they were discovered in.
+type GEXF struct {
+	*graphs.Recorder
+}
+
+var (
+	_ graphs.CliGraphProvider = (*GEXF)(nil)
+	_ graphs.CrawlNotifier    = (*GEXF)(nil)
+)
+
+func NewGEXF() *GEXF {
+	return &GEXF{Recorder: graphs.NewRecorder()}
+}
+
+func (g *GEXF) toDocument() document {
+	recordedNodes, recordedEdges := g.Snapshot()
+
+	edges := make([]edge, len(recordedEdges))
+	for i, e := range recordedEdges {
+		edges[i] = edge{
+			ID:        strconv.Itoa(i),
+			Source:    strconv.Itoa(e.From),
+			Target:    strconv.Itoa(e.To),
+			Kind:      string(e.Kind),
+			Start:     e.Discovered.Format(dateTime),
+			AttValues: attValues(graphs.EdgeAttributes, e.AttributeValues()),
+		}
+	}
+
+	nodes := make([]node, len(recordedNodes))
+	for i, n := range recordedNodes {
+		nodes[i] = node{
+			ID:        strconv.Itoa(i),
+			Label:     n.Hostname,
+			Start:     n.Discovered.Format(dateTime),
+			AttValues: attValues(graphs.NodeAttributes, n.AttributeValues()),
+		}
+	}
+
+	return document{
+		Xmlns:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Meta: meta{
+			LastModified: time.Now().Format(time.DateOnly),
+			Creator:      "nomad",
+			Description:  "Hostname connections found by crawling",
+		},
+		Graph: graph{
+			Mode:            "dynamic",
+			DefaultEdgeType: "directed",
+			TimeFormat:      "dateTime",
+			Attributes: []attributes{
+				declare("node", graphs.NodeAttributes),
+				declare("edge", graphs.EdgeAttributes),
+			},
+			Nodes: nodes,
+			Edges: edges,
+		},
+	}
+}
+
+// declare returns the declaration of a class of attributes, GEXF uses the same type names
+// as graphs.AttributeType.
+func declare(class string, attrs []graphs.Attribute) attributes {
+	declared := attributes{Class: class, Mode: "static"}
+	for _, attr := range attrs {
+		declared.Attributes = append(declared.Attributes, attribute{ID: attr.ID, Title: attr.Title, Type: string(attr.Type)})
+	}
+	return declared
+}
+
+func attValues(attrs []graphs.Attribute, values []string) []attValue {
+	attValues := make([]attValue, len(attrs))
+	for i, attr := range attrs {
+		attValues[i] = attValue{For: attr.ID, Value: values[i]}
+	}
+	return attValues
+}
+
+func (g *GEXF) Extension() string {
+	return ".gexf"
+}
+
+func (g *GEXF) Render(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(g.toDocument()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,188 @@
+package gexf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// element is any XML element, the output is parsed in to these rather than the types it
+// was written from so that mistakes in them aren't hidden.
+type element struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []element  `xml:",any"`
+}
+
+func (e element) attr(name string) string {
+	for _, attr := range e.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// all returns the descendants at path, e.g. "nodes/node".
+func (e element) all(path ...string) []element {
+	found := []element{e}
+	for _, name := range path {
+		var next []element
+		for _, parent := range found {
+			for _, child := range parent.Children {
+				if child.XMLName.Local == name {
+					next = append(next, child)
+				}
+			}
+		}
+		found = next
+	}
+	return found
+}
+
+func render(t *testing.T, g *GEXF) element {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := g.Render(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var root element
+	if err := xml.Unmarshal(buf.Bytes(), &root); err != nil {
+		t.Fatalf("output isn't valid XML: %v", err)
+	}
+	return root
+}
+
+func TestRender(t *testing.T) {
+	g := NewGEXF()
+	g.AddHostnameConnection(graphs.Edge{
+		From:       "a.com",
+		To:         "b.com",
+		Kind:       graphs.LinkAnchor,
+		SourceUrl:  "https://a.com/page",
+		AnchorText: `B & "friends" <3`,
+		Rel:        []string{"nofollow", "ugc"},
+	})
+	g.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkImage})
+	g.AddHostnameConnection(graphs.Edge{From: "b.com", To: "c.com", Kind: graphs.LinkAnchor})
+	g.NotifyEndCrawl(1, "a.com", false)
+	g.NotifyEndCrawl(1, "c.com", true)
+
+	root := render(t, g)
+	if root.XMLName.Space != "http://gexf.net/1.3" || root.XMLName.Local != "gexf" || root.attr("version") != "1.3" {
+		t.Fatalf("root is %s in %s version %s, expected gexf 1.3", root.XMLName.Local, root.XMLName.Space, root.attr("version"))
+	}
+	found := root.all("graph")
+	if len(found) != 1 {
+		t.Fatalf("got %d graphs, expected 1", len(found))
+	}
+	graph := found[0]
+	// Start times are only read as dates if the time format says so.
+	if graph.attr("mode") != "dynamic" || graph.attr("timeformat") != "dateTime" || graph.attr("defaultedgetype") != "directed" {
+		t.Errorf("graph has attributes %v", graph.Attrs)
+	}
+
+	// Every attvalue has to refer to an attribute declared for its class.
+	declared := make(map[string]string)
+	for _, class := range graph.all("attributes") {
+		for _, attr := range class.all("attribute") {
+			declared[class.attr("class")+"/"+attr.attr("id")] = attr.attr("type")
+		}
+	}
+	for _, id := range []string{"node/crawled", "node/deadEnd", "node/indegree", "node/outdegree", "edge/kind", "edge/sourceUrl", "edge/anchorText", "edge/rel"} {
+		if declared[id] == "" {
+			t.Errorf("attribute %s isn't declared", id)
+		}
+	}
+	attValues := func(class string, e element) map[string]string {
+		t.Helper()
+		values := make(map[string]string)
+		for _, v := range e.all("attvalues", "attvalue") {
+			if declared[class+"/"+v.attr("for")] == "" {
+				t.Errorf("%s attvalue for %s isn't declared", class, v.attr("for"))
+			}
+			values[v.attr("for")] = v.attr("value")
+		}
+		return values
+	}
+
+	expectedNodes := map[string]map[string]string{
+		"a.com": {"crawled": "true", "deadEnd": "false", "indegree": "0", "outdegree": "1"},
+		"b.com": {"crawled": "false", "deadEnd": "false", "indegree": "1", "outdegree": "1"},
+		"c.com": {"crawled": "true", "deadEnd": "true", "indegree": "1", "outdegree": "0"},
+	}
+	labels := make(map[string]string)
+	nodes := graph.all("nodes", "node")
+	if len(nodes) != len(expectedNodes) {
+		t.Fatalf("got %d nodes, expected %d", len(nodes), len(expectedNodes))
+	}
+	for _, n := range nodes {
+		label := n.attr("label")
+		if _, ok := labels[n.attr("id")]; ok {
+			t.Errorf("node id %s is used twice", n.attr("id"))
+		}
+		labels[n.attr("id")] = label
+		if _, err := time.Parse(time.RFC3339, n.attr("start")); err != nil {
+			t.Errorf("node %s start isn't a dateTime: %v", label, err)
+		}
+		values := attValues("node", n)
+		for id, expected := range expectedNodes[label] {
+			if values[id] != expected {
+				t.Errorf("node %s has %s %q, expected %q", label, id, values[id], expected)
+			}
+		}
+	}
+
+	type expectedEdge struct {
+		from, to, kind string
+		values         map[string]string
+	}
+	expectedEdges := []expectedEdge{
+		{"a.com", "b.com", "a", map[string]string{"kind": "a", "sourceUrl": "https://a.com/page", "anchorText": `B & "friends" <3`, "rel": "nofollow ugc"}},
+		{"a.com", "b.com", "img", map[string]string{"kind": "img", "sourceUrl": "", "anchorText": "", "rel": ""}},
+		{"b.com", "c.com", "a", map[string]string{"kind": "a"}},
+	}
+	edges := graph.all("edges", "edge")
+	if len(edges) != len(expectedEdges) {
+		t.Fatalf("got %d edges, expected %d", len(edges), len(expectedEdges))
+	}
+	ids := make(map[string]bool)
+	for i, e := range edges {
+		expected := expectedEdges[i]
+		if ids[e.attr("id")] {
+			t.Errorf("edge id %s is used twice", e.attr("id"))
+		}
+		ids[e.attr("id")] = true
+		from, fromOk := labels[e.attr("source")]
+		to, toOk := labels[e.attr("target")]
+		if !fromOk || !toOk || from != expected.from || to != expected.to {
+			t.Errorf("edge %d is from %q to %q, expected %s to %s", i, from, to, expected.from, expected.to)
+		}
+		// Without a kind Gephi merges the parallel edges from a.com to b.com.
+		if e.attr("kind") != expected.kind {
+			t.Errorf("edge %d has kind %q, expected %q", i, e.attr("kind"), expected.kind)
+		}
+		if _, err := time.Parse(time.RFC3339, e.attr("start")); err != nil {
+			t.Errorf("edge %d start isn't a dateTime: %v", i, err)
+		}
+		values := attValues("edge", e)
+		for id, value := range expected.values {
+			if values[id] != value {
+				t.Errorf("edge %d has %s %q, expected %q", i, id, values[id], value)
+			}
+		}
+	}
+}
+
+func TestRenderEmpty(t *testing.T) {
+	root := render(t, NewGEXF())
+	if nodes := root.all("graph", "nodes", "node"); len(nodes) != 0 {
+		t.Errorf("got %d nodes, expected none", len(nodes))
+	}
+	if len(root.all("meta", "creator")) != 1 {
+		t.Error("meta has no creator")
+	}
+}
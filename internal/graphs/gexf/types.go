@@ -0,0 +1,63 @@
+package gexf
+
+import "encoding/xml"
+
+// These follow the GEXF 1.3 schema, see https://gexf.net/schema.html.
+
+type document struct {
+	XMLName xml.Name `xml:"gexf"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Meta    meta     `xml:"meta"`
+	Graph   graph    `xml:"graph"`
+}
+
+type meta struct {
+	LastModified string `xml:"lastmodifieddate,attr"`
+	Creator      string `xml:"creator"`
+	Description  string `xml:"description"`
+}
+
+type graph struct {
+	Mode            string       `xml:"mode,attr"`
+	DefaultEdgeType string       `xml:"defaultedgetype,attr"`
+	TimeFormat      string       `xml:"timeformat,attr"`
+	Attributes      []attributes `xml:"attributes"`
+	Nodes           []node       `xml:"nodes>node"`
+	Edges           []edge       `xml:"edges>edge"`
+}
+
+type attributes struct {
+	Class      string      `xml:"class,attr"`
+	Mode       string      `xml:"mode,attr"`
+	Attributes []attribute `xml:"attribute"`
+}
+
+type attribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type node struct {
+	ID        string     `xml:"id,attr"`
+	Label     string     `xml:"label,attr"`
+	Start     string     `xml:"start,attr"`
+	AttValues []attValue `xml:"attvalues>attvalue"`
+}
+
+// edge's Kind is set so that connections of different kinds between the same hostnames
+// are kept as parallel edges, rather than merged in to one.
+type edge struct {
+	ID        string     `xml:"id,attr"`
+	Source    string     `xml:"source,attr"`
+	Target    string     `xml:"target,attr"`
+	Kind      string     `xml:"kind,attr"`
+	Start     string     `xml:"start,attr"`
+	AttValues []attValue `xml:"attvalues>attvalue"`
+}
+
+type attValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
@@ -0,0 +1,114 @@
+package graphml
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// GraphML defines a CliGraphProvider that renders a GraphML file, which can be read by
+// NetworkX, Gephi, yEd, etc.
+type GraphML struct {
+	*graphs.Recorder
+}
+
+var (
+	_ graphs.CliGraphProvider = (*GraphML)(nil)
+	_ graphs.CrawlNotifier    = (*GraphML)(nil)
+)
+
+func NewGraphML() *GraphML {
+	return &GraphML{Recorder: graphs.NewRecorder()}
+}
+
+func (g *GraphML) toDocument() document {
+	recordedNodes, recordedEdges := g.Snapshot()
+
+	edges := make([]edge, len(recordedEdges))
+	for i, e := range recordedEdges {
+		edges[i] = edge{
+			ID:     "e" + strconv.Itoa(i),
+			Source: "n" + strconv.Itoa(e.From),
+			Target: "n" + strconv.Itoa(e.To),
+			Data: append(
+				[]data{{Key: "discovered", Value: e.Discovered.Format(time.RFC3339Nano)}},
+				values(graphs.EdgeAttributes, e.AttributeValues())...,
+			),
+		}
+	}
+
+	nodes := make([]node, len(recordedNodes))
+	for i, n := range recordedNodes {
+		nodes[i] = node{
+			ID: "n" + strconv.Itoa(i),
+			Data: append([]data{
+				{Key: "label", Value: n.Hostname},
+				{Key: "order", Value: strconv.Itoa(i)},
+				{Key: "discovered", Value: n.Discovered.Format(time.RFC3339Nano)},
+			}, values(graphs.NodeAttributes, n.AttributeValues())...),
+		}
+	}
+
+	keys := []key{
+		{ID: "label", For: "node", Name: "label", Type: "string"},
+		{ID: "order", For: "node", Name: "order", Type: "int"},
+		{ID: "discovered", For: "all", Name: "discovered", Type: "string"},
+	}
+	keys = append(keys, declare("node", graphs.NodeAttributes)...)
+	keys = append(keys, declare("edge", graphs.EdgeAttributes)...)
+
+	return document{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  keys,
+		Graph: graph{
+			ID:          "nomad",
+			EdgeDefault: "directed",
+			Nodes:       nodes,
+			Edges:       edges,
+		},
+	}
+}
+
+// declare returns a key for each attribute. Booleans default to false.
+func declare(domain string, attrs []graphs.Attribute) []key {
+	keys := make([]key, len(attrs))
+	for i, attr := range attrs {
+		keys[i] = key{ID: attr.ID, For: domain, Name: attr.ID}
+		switch attr.Type {
+		case graphs.AttributeBoolean:
+			keys[i].Type, keys[i].Default = "boolean", "false"
+		case graphs.AttributeInteger:
+			keys[i].Type = "int"
+		default:
+			keys[i].Type = "string"
+		}
+	}
+	return keys
+}
+
+func values(attrs []graphs.Attribute, values []string) []data {
+	attrData := make([]data, len(attrs))
+	for i, attr := range attrs {
+		attrData[i] = data{Key: attr.ID, Value: values[i]}
+	}
+	return attrData
+}
+
+func (g *GraphML) Extension() string {
+	return ".graphml"
+}
+
+func (g *GraphML) Render(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(g.toDocument()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
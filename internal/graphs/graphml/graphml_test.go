@@ -0,0 +1,195 @@
+package graphml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// element is any XML element, the output is parsed in to these rather than the types it
+// was written from so that mistakes in them aren't hidden.
+type element struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []element  `xml:",any"`
+	Text     string     `xml:",chardata"`
+}
+
+func (e element) attr(name string) string {
+	for _, attr := range e.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func (e element) children(name string) []element {
+	var found []element
+	for _, child := range e.Children {
+		if child.XMLName.Local == name {
+			found = append(found, child)
+		}
+	}
+	return found
+}
+
+// graphKey is a GraphML <key>, which declares a kind of <data> for a domain.
+type graphKey struct {
+	domain, name, typ, def string
+}
+
+// keyTypes are the types GraphML allows for attr.type.
+var keyTypes = map[string]bool{"boolean": true, "int": true, "long": true, "float": true, "double": true, "string": true}
+
+// parse checks the document's structure and returns its keys by ID and its graph.
+func parse(t *testing.T, raw []byte) (map[string]graphKey, element) {
+	t.Helper()
+	var root element
+	if err := xml.Unmarshal(raw, &root); err != nil {
+		t.Fatalf("output isn't valid XML: %v", err)
+	}
+	if root.XMLName.Space != "http://graphml.graphdrawing.org/xmlns" || root.XMLName.Local != "graphml" {
+		t.Fatalf("root is %s in %s, expected graphml", root.XMLName.Local, root.XMLName.Space)
+	}
+
+	keys := make(map[string]graphKey)
+	for _, k := range root.children("key") {
+		domain := k.attr("for")
+		if domain != "node" && domain != "edge" && domain != "all" {
+			t.Errorf("key %s is for %q", k.attr("id"), domain)
+		}
+		if !keyTypes[k.attr("attr.type")] {
+			t.Errorf("key %s has type %q", k.attr("id"), k.attr("attr.type"))
+		}
+		if _, ok := keys[k.attr("id")]; ok {
+			t.Errorf("key %s is declared twice", k.attr("id"))
+		}
+		declared := graphKey{domain: domain, name: k.attr("attr.name"), typ: k.attr("attr.type")}
+		if defaults := k.children("default"); len(defaults) == 1 {
+			declared.def = defaults[0].Text
+		}
+		keys[k.attr("id")] = declared
+	}
+
+	found := root.children("graph")
+	if len(found) != 1 {
+		t.Fatalf("got %d graphs, expected 1", len(found))
+	}
+	// Keys have to come before the graph.
+	if last := root.Children[len(root.Children)-1]; last.XMLName.Local != "graph" {
+		t.Errorf("%s comes after the graph", last.XMLName.Local)
+	}
+	return keys, found[0]
+}
+
+// dataOf returns e's data by key, checking each key is declared for domain.
+func dataOf(t *testing.T, keys map[string]graphKey, domain string, e element) map[string]string {
+	t.Helper()
+	found := make(map[string]string)
+	for _, d := range e.children("data") {
+		k, ok := keys[d.attr("key")]
+		if !ok || (k.domain != domain && k.domain != "all") {
+			t.Errorf("%s data %s isn't declared for it", domain, d.attr("key"))
+		}
+		found[d.attr("key")] = d.Text
+	}
+	return found
+}
+
+func TestRender(t *testing.T) {
+	g := NewGraphML()
+	g.AddHostnameConnection(graphs.Edge{
+		From:       "a.com",
+		To:         "b.com",
+		Kind:       graphs.LinkAnchor,
+		SourceUrl:  "https://a.com/page",
+		AnchorText: `B & "friends" <3`,
+		Rel:        []string{"nofollow", "ugc"},
+	})
+	g.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkImage})
+	g.AddHostnameConnection(graphs.Edge{From: "b.com", To: "c.com", Kind: graphs.LinkAnchor})
+	g.NotifyEndCrawl(1, "a.com", false)
+	g.NotifyEndCrawl(1, "c.com", true)
+
+	var buf bytes.Buffer
+	if err := g.Render(&buf); err != nil {
+		t.Fatal(err)
+	}
+	keys, graph := parse(t, buf.Bytes())
+
+	if graph.attr("edgedefault") != "directed" {
+		t.Errorf("edge default is %q, expected directed", graph.attr("edgedefault"))
+	}
+	for id, expected := range map[string]graphKey{
+		"label":      {domain: "node", name: "label", typ: "string"},
+		"crawled":    {domain: "node", name: "crawled", typ: "boolean", def: "false"},
+		"deadEnd":    {domain: "node", name: "deadEnd", typ: "boolean", def: "false"},
+		"indegree":   {domain: "node", name: "indegree", typ: "int"},
+		"discovered": {domain: "all", name: "discovered", typ: "string"},
+		"kind":       {domain: "edge", name: "kind", typ: "string"},
+		"rel":        {domain: "edge", name: "rel", typ: "string"},
+	} {
+		if keys[id] != expected {
+			t.Errorf("key %s is %+v, expected %+v", id, keys[id], expected)
+		}
+	}
+
+	expectedNodes := map[string]map[string]string{
+		"a.com": {"order": "0", "crawled": "true", "deadEnd": "false", "indegree": "0", "outdegree": "1"},
+		"b.com": {"order": "1", "crawled": "false", "deadEnd": "false", "indegree": "1", "outdegree": "1"},
+		"c.com": {"order": "2", "crawled": "true", "deadEnd": "true", "indegree": "1", "outdegree": "0"},
+	}
+	labels := make(map[string]string)
+	nodes := graph.children("node")
+	if len(nodes) != len(expectedNodes) {
+		t.Fatalf("got %d nodes, expected %d", len(nodes), len(expectedNodes))
+	}
+	for _, n := range nodes {
+		got := dataOf(t, keys, "node", n)
+		labels[n.attr("id")] = got["label"]
+		if _, err := time.Parse(time.RFC3339Nano, got["discovered"]); err != nil {
+			t.Errorf("node %s discovered: %v", got["label"], err)
+		}
+		for id, expected := range expectedNodes[got["label"]] {
+			if got[id] != expected {
+				t.Errorf("node %s has %s %q, expected %q", got["label"], id, got[id], expected)
+			}
+		}
+	}
+
+	// The two kinds of connection from a.com to b.com are kept as parallel edges.
+	expectedEdges := []struct {
+		from, to string
+		data     map[string]string
+	}{
+		{"a.com", "b.com", map[string]string{"kind": "a", "sourceUrl": "https://a.com/page", "anchorText": `B & "friends" <3`, "rel": "nofollow ugc"}},
+		{"a.com", "b.com", map[string]string{"kind": "img", "sourceUrl": "", "anchorText": "", "rel": ""}},
+		{"b.com", "c.com", map[string]string{"kind": "a"}},
+	}
+	edges := graph.children("edge")
+	if len(edges) != len(expectedEdges) {
+		t.Fatalf("got %d edges, expected %d", len(edges), len(expectedEdges))
+	}
+	ids := make(map[string]bool)
+	for i, e := range edges {
+		if ids[e.attr("id")] {
+			t.Errorf("edge id %s is used twice", e.attr("id"))
+		}
+		ids[e.attr("id")] = true
+		from, fromOk := labels[e.attr("source")]
+		to, toOk := labels[e.attr("target")]
+		if !fromOk || !toOk || from != expectedEdges[i].from || to != expectedEdges[i].to {
+			t.Errorf("edge %d is from %q to %q, expected %s to %s", i, from, to, expectedEdges[i].from, expectedEdges[i].to)
+		}
+		got := dataOf(t, keys, "edge", e)
+		for id, expected := range expectedEdges[i].data {
+			if got[id] != expected {
+				t.Errorf("edge %d has %s %q, expected %q", i, id, got[id], expected)
+			}
+		}
+	}
+}
@@ -2,7 +2,7 @@ package graphology
 
 import (
 	"encoding/json"
-	"os"
+	"io"
 	"strconv"
 	"sync"
 
@@ -17,29 +17,32 @@ type Graphology struct {
 	hasher          *StrHasher
 	graphologyGraph *SerializedGraph
 	nodes           map[string]*Node
+	nodeOrder       []string
 	seenNodes       Set
-	seenEdges       Set
-	edgeCount       int
+	weights         *graphs.EdgeWeights
 }
 
 var _ graphs.CliGraphProvider = (*Graphology)(nil)
 
-func NewGraphology() *Graphology {
+// NewGraphology creates a Graphology, if collapseDirection is true links in either
+// direction between two hostnames are counted in one undirected edge.
+func NewGraphology(collapseDirection bool) *Graphology {
 	return &Graphology{
 		mu:              &sync.Mutex{},
 		hasher:          NewStrHasher(),
 		graphologyGraph: &SerializedGraph{},
 		nodes:           make(map[string]*Node),
 		seenNodes:       NewSet(),
-		seenEdges:       NewSet(),
-		edgeCount:       0,
+		weights:         graphs.NewEdgeWeights(collapseDirection),
 	}
 }
 
-func (g *Graphology) AddHostnameConnection(fromHost, toHost string) {
+func (g *Graphology) AddHostnameConnection(connection graphs.Edge) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	fromHost, toHost := connection.From, connection.To
+
 	fromHostId := strconv.Itoa(g.hasher.Hash(fromHost))
 	if !g.seenNodes.Contains(fromHostId) {
 		g.seenNodes.Add(fromHostId)
@@ -49,6 +52,7 @@ func (g *Graphology) AddHostnameConnection(fromHost, toHost string) {
 			Label: fromHost, Color: "blue",
 		}
 		g.nodes[fromHostId] = &fromHostNode
+		g.nodeOrder = append(g.nodeOrder, fromHostId)
 	} else {
 		if g.nodes[fromHostId].Attributes.Size < 10 {
 			g.nodes[fromHostId].Attributes.Size += 0.2
@@ -64,56 +68,52 @@ func (g *Graphology) AddHostnameConnection(fromHost, toHost string) {
 			Label: toHost, Color: "blue",
 		}
 		g.nodes[toHostId] = &toHostNode
+		g.nodeOrder = append(g.nodeOrder, toHostId)
 	}
 
-	edgeStr := fromHostId + "\t" + toHostId
-	inverseEdgeStr := toHostId + "\t" + fromHostId
-
-	if !g.seenEdges.Contains(edgeStr) && !g.seenEdges.Contains(inverseEdgeStr) {
-		g.seenEdges.Add(edgeStr)
-		g.edgeCount++
+	// Hostnames are only connected once, styled by the kind of the first link found and
+	// weighted by how many links there are. The edges are in the same order as weights.
+	i, isNew := g.weights.Add(connection)
+	if i < 0 {
+		return
+	}
+	if isNew {
 		edge := Edge{
-			Key:    strconv.Itoa(g.edgeCount),
-			Source: fromHostId,
-			Target: toHostId,
+			Key:        strconv.Itoa(i + 1),
+			Source:     fromHostId,
+			Target:     toHostId,
+			Undirected: g.weights.CollapseDirection(),
 			Attributes: EdgeAttributes{
-				Size: 2,
+				Color: connection.Kind.Color(),
+				Kind:  string(connection.Kind),
+				Rel:   connection.Rel,
 			},
 		}
 		g.graphologyGraph.Edges = append(g.graphologyGraph.Edges, edge)
 	}
-}
 
-func (g Graphology) enrichGraph() {
-	// Add in all our nodes
-	for _, node := range g.nodes {
-		g.graphologyGraph.Nodes = append(g.graphologyGraph.Nodes, *node)
-	}
+	weighted := g.weights.Get(i)
+	attributes := &g.graphologyGraph.Edges[i].Attributes
+	attributes.Weight = weighted.Weight
+	attributes.Size = graphs.EdgeSize(weighted.Weight)
+	attributes.Bidirectional = weighted.Bidirectional()
 }
 
-func (g Graphology) RenderToFile(filename string) error {
-	filename = filename + ".json"
+func (g *Graphology) Extension() string {
+	return ".json"
+}
 
+func (g *Graphology) Render(w io.Writer) error {
+	// Copy the graph so the lock isn't held while marshalling.
 	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	g.enrichGraph()
-
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
+	graph := SerializedGraph{
+		Nodes: make([]Node, len(g.nodeOrder)),
+		Edges: append([]Edge{}, g.graphologyGraph.Edges...),
 	}
-	defer file.Close()
-
-	marshalled, err := json.Marshal(g.graphologyGraph)
-	if err != nil {
-		return err
-	}
-
-	_, err = file.Write(marshalled)
-	if err != nil {
-		return err
+	for i, id := range g.nodeOrder {
+		graph.Nodes[i] = *g.nodes[id]
 	}
+	g.mu.Unlock()
 
-	return nil
+	return json.NewEncoder(w).Encode(graph)
 }
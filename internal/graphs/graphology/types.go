@@ -14,13 +14,20 @@ type Node struct {
 }
 
 type EdgeAttributes struct {
-	Size int `json:"size"`
+	Size   float64  `json:"size"`
+	Color  string   `json:"color"`
+	Kind   string   `json:"kind"`
+	Rel    []string `json:"rel,omitempty"`
+	Weight int      `json:"weight"` // How many links the edge is.
+	// Bidirectional is only set for undirected edges with links in both directions.
+	Bidirectional bool `json:"bidirectional,omitempty"`
 }
 
 type Edge struct {
 	Key        string         `json:"key"`
 	Source     string         `json:"source"`
 	Target     string         `json:"target"`
+	Undirected bool           `json:"undirected,omitempty"`
 	Attributes EdgeAttributes `json:"attributes"`
 }
 
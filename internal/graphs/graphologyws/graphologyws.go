@@ -20,8 +20,7 @@ type GraphologyWs struct {
 	ws     lib.ThreadSafeWebSocket
 	// Keep track of nodes and edges so we know what's been seen.
 	seenNodes Set
-	seenEdges Set
-	edgeCount int
+	weights   *graphs.EdgeWeights
 }
 
 var _ graphs.WebsocketGraphProvider = (*GraphologyWs)(nil)
@@ -29,21 +28,24 @@ var _ graphs.WebsocketGraphProvider = (*GraphologyWs)(nil)
 // All of the websocket messages sent by GraphologyWs will be text.
 var t = websocket.TextMessage
 
-func NewGraphologyWs(ws lib.ThreadSafeWebSocket) *GraphologyWs {
+// NewGraphologyWs creates a GraphologyWs, if collapseDirection is true links in either
+// direction between two hostnames are counted in one undirected edge.
+func NewGraphologyWs(ws lib.ThreadSafeWebSocket, collapseDirection bool) *GraphologyWs {
 	return &GraphologyWs{
 		mu:        &sync.Mutex{},
 		hasher:    NewStrHasher(),
 		ws:        ws,
 		seenNodes: NewSet(),
-		seenEdges: NewSet(),
-		edgeCount: 0,
+		weights:   graphs.NewEdgeWeights(collapseDirection),
 	}
 }
 
-func (g *GraphologyWs) AddHostnameConnection(fromHost, toHost string) {
+func (g *GraphologyWs) AddHostnameConnection(connection graphs.Edge) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	fromHost, toHost := connection.From, connection.To
+
 	// Get the unique ID for this host.
 	fromHostId := strconv.Itoa(g.hasher.Hash(fromHost))
 	// Create the data structure.
@@ -77,18 +79,30 @@ func (g *GraphologyWs) AddHostnameConnection(fromHost, toHost string) {
 		}
 	}
 
-	// Check if we've seen this edge before in either direction - use tab as a separator
-	// as it's impossible to appear in the IDs.
-	edgeStr := fromHostId + "\t" + toHostId
-	inverseEdgeStr := toHostId + "\t" + fromHostId
-
-	if !g.seenEdges.Contains(edgeStr) && !g.seenEdges.Contains(inverseEdgeStr) {
-		// Only need to add the first because we check for both each time
-		g.seenEdges.Add(edgeStr)
-		g.edgeCount++
+	// Count the links, the edge index is also its key.
+	i, isNew := g.weights.Add(connection)
+	if i < 0 {
+		return
+	}
+	weighted := g.weights.Get(i)
+	e := edge{
+		Key:        strconv.Itoa(i + 1),
+		Source:     fromHostId,
+		Target:     toHostId,
+		Undirected: g.weights.CollapseDirection(),
+		Kind:       string(weighted.Kind),
+		Color:      weighted.Kind.Color(),
+		Weight:     weighted.Weight,
+	}
 
-		edge := edge{strconv.Itoa(g.edgeCount), fromHostId, toHostId}
-		if err := g.ws.WriteMessage(t, edge.toEdgeJson()); err != nil {
+	if isNew {
+		// The edge is styled by the kind of the first link found.
+		if err := g.ws.WriteMessage(t, e.toEdgeJson()); err != nil {
+			log.Print("ws.WriteMessage err:", err)
+		}
+	} else {
+		// Inform the frontend that there are more links.
+		if err := g.ws.WriteMessage(t, e.toEdgeUpdateJson()); err != nil {
 			log.Print("ws.WriteMessage err:", err)
 		}
 	}
@@ -34,13 +34,25 @@ func (n node) toNodeUpdateJson() []byte {
 }
 
 type edge struct {
-	Key    string `json:"key"`
-	Source string `json:"source"`
-	Target string `json:"target"`
+	Key        string `json:"key"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Undirected bool   `json:"undirected"`
+	Kind       string `json:"kind"`
+	Color      string `json:"color"`
+	Weight     int    `json:"weight"`
 }
 
 func (e edge) toEdgeJson() []byte {
 	return []byte(fmt.Sprintf(
-		`{"type": "edge", "data": {"from": "%s", "to": "%s"}}`, e.Source, e.Target,
+		`{"type": "edge", "data": {"key": "%s", "from": "%s", "to": "%s", "undirected": %t, "kind": "%s", "color": "%s", "weight": %d}}`,
+		e.Key, e.Source, e.Target, e.Undirected, e.Kind, e.Color, e.Weight,
+	))
+}
+
+func (e edge) toEdgeUpdateJson() []byte {
+	// Only e.Key and e.Weight need to be set.
+	return []byte(fmt.Sprintf(
+		`{"type": "edgeupdate", "data": {"key": "%s", "weight": %d}}`, e.Key, e.Weight,
 	))
 }
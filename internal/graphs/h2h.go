@@ -2,67 +2,61 @@ package graphs
 
 import (
 	"encoding/json"
-	"os"
+	"io"
 	"sync"
-
-	. "github.com/psidex/nomad/internal/lib"
 )
 
-// HostnameGraph defines a CliGraphProvider that keeps track of hostname connections using
-// a map[string]Set and renders this to a JSON file. It does not de-duplicate edges.
+// HostnameGraph defines a CliGraphProvider that keeps track of hostname connections and
+// renders them to a JSON file, mapping each hostname to the hostnames it links to and
+// how many links there are.
 type HostnameGraph struct {
-	mu                *sync.RWMutex
-	hostname2hostname map[string]Set
+	mu      *sync.Mutex
+	weights *EdgeWeights
 }
 
 var _ CliGraphProvider = (*HostnameGraph)(nil)
 
+// NewHostnameGraph creates a HostnameGraph. Each direction is always counted separately,
+// as the JSON maps each hostname to the ones it links to.
 func NewHostnameGraph() HostnameGraph {
 	return HostnameGraph{
-		mu:                &sync.RWMutex{},
-		hostname2hostname: make(map[string]Set),
+		mu:      &sync.Mutex{},
+		weights: NewEdgeWeights(false),
 	}
 }
 
-func (h HostnameGraph) AddHostnameConnection(fromHost, toHost string) {
+func (h HostnameGraph) AddHostnameConnection(edge Edge) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	if _, ok := h.hostname2hostname[fromHost]; !ok {
-		h.hostname2hostname[fromHost] = NewSet()
-	}
-	h.hostname2hostname[fromHost].Add(toHost)
+	h.weights.Add(edge)
 }
 
 func (h HostnameGraph) toJson() ([]byte, error) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-
-	slicedSets := make(map[string][]string)
-	for key, value := range h.hostname2hostname {
-		slicedSets[key] = value.AsSlice()
+	h.mu.Lock()
+	edges := h.weights.Edges()
+	h.mu.Unlock()
+
+	hostname2hostname := make(map[string]map[string]int)
+	for _, edge := range edges {
+		if _, ok := hostname2hostname[edge.From]; !ok {
+			hostname2hostname[edge.From] = make(map[string]int)
+		}
+		hostname2hostname[edge.From][edge.To] = edge.Weight
 	}
 
-	return json.MarshalIndent(slicedSets, "", "  ")
+	return json.MarshalIndent(hostname2hostname, "", "  ")
 }
 
-func (h HostnameGraph) RenderToFile(filename string) error {
-	filename = filename + ".json"
+func (h HostnameGraph) Extension() string {
+	return ".json"
+}
 
+func (h HostnameGraph) Render(w io.Writer) error {
 	jsonData, err := h.toJson()
 	if err != nil {
 		return err
 	}
 
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	_, err = file.Write(jsonData)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	_, err = w.Write(jsonData)
+	return err
 }
@@ -0,0 +1,32 @@
+package graphs
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHostnameGraphDirection(t *testing.T) {
+	h := NewHostnameGraph()
+	h.AddHostnameConnection(Edge{From: "a.example", To: "b.example", Kind: LinkAnchor, Count: 2})
+	h.AddHostnameConnection(Edge{From: "b.example", To: "a.example", Kind: LinkAnchor})
+	h.AddHostnameConnection(Edge{From: "a.example", To: "c.example", Kind: LinkScript})
+
+	var buf bytes.Buffer
+	if err := h.Render(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]map[string]int
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]map[string]int{
+		"a.example": {"b.example": 2, "c.example": 1},
+		"b.example": {"a.example": 1},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
@@ -1,10 +1,16 @@
 package graphs
 
+import (
+	"io"
+	"time"
+)
+
 // GraphProvider defines an interface that can be used to track connections between
 // hostnames.
 type GraphProvider interface {
-	// AddHostnameConnection should be thread-safe.
-	AddHostnameConnection(fromHost, toHost string)
+	// AddHostnameConnection should be thread-safe. The same hostnames and kind can be
+	// connected by many edges, one for each page they're found in.
+	AddHostnameConnection(edge Edge)
 }
 
 // CliGraphProvider extends the GraphProvider interface to accommodate CLI
@@ -12,9 +18,20 @@ type GraphProvider interface {
 type CliGraphProvider interface {
 	GraphProvider
 
-	// RenderToFile is not assumed to be thread-safe.
-	// filename should be the desired file name without an extension.
-	RenderToFile(filename string) error
+	// Render writes the graph to w. It should be thread-safe and have no side effects,
+	// so it can be called any number of times, including while the crawl is running.
+	Render(w io.Writer) error
+
+	// Extension is the file extension for Render's output, including the dot.
+	Extension() string
+}
+
+// CrawlNotifier can be implemented by any GraphProvider that wants to know when each
+// hostname starts and finishes being crawled.
+type CrawlNotifier interface {
+	// These should be thread-safe.
+	NotifyStartCrawl(workerId uint, hostname string)
+	NotifyEndCrawl(workerId uint, hostname string, deadEnd bool)
 }
 
 // WebsocketGraphProvider extends the GraphProvider interface to accommodate WebSocket
@@ -26,6 +43,24 @@ type WebsocketGraphProvider interface {
 	// client that the given hostname is being crawled / is finished being crawled. This
 	// is needed because only the provider knows the ID for a given hostname, which is
 	// required to update the frontend correctly.
-	NotifyStartCrawl(workerId uint, hostname string)
-	NotifyEndCrawl(workerId uint, hostname string, deadEnd bool)
+	CrawlNotifier
+}
+
+// FetchResult is the outcome of fetching a single URL.
+type FetchResult struct {
+	Url        string
+	Hostname   string
+	WorkerId   uint
+	Started    time.Time
+	Duration   time.Duration
+	StatusCode int // 0 if there was no response.
+	Links      int // How many links were found on the page.
+	Err        error
+}
+
+// FetchRecorder can be implemented by any GraphProvider that wants the result of every
+// fetch, not just the connections found.
+type FetchRecorder interface {
+	// RecordFetch should be thread-safe.
+	RecordFetch(result FetchResult)
 }
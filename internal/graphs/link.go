@@ -0,0 +1,84 @@
+package graphs
+
+import "fmt"
+
+// LinkKind is the part of a page that a connection was found in.
+type LinkKind string
+
+const (
+	LinkAnchor      LinkKind = "a"            // <a href>
+	LinkLink        LinkKind = "link"         // <link href>, e.g. stylesheets, preconnect, canonical
+	LinkScript      LinkKind = "script"       // <script src>
+	LinkImage       LinkKind = "img"          // <img src> and srcset
+	LinkIframe      LinkKind = "iframe"       // <iframe src>
+	LinkForm        LinkKind = "form"         // <form action>
+	LinkMetaRefresh LinkKind = "meta-refresh" // <meta http-equiv="refresh">
+	LinkMeta        LinkKind = "meta"         // Open Graph and Twitter card <meta> URLs
+	LinkTLSSan      LinkKind = "tls-san"      // Shares a TLS certificate, from its Subject Alternative Names
+	// LinkRedirect is an HTTP redirect to another hostname, not a link source. They're
+	// always recorded, and queued like any other link.
+	LinkRedirect LinkKind = "redirect"
+)
+
+// LinkKinds are all the link sources that can be enabled, in the order they're
+// documented.
+var LinkKinds = []LinkKind{
+	LinkAnchor, LinkLink, LinkScript, LinkImage, LinkIframe, LinkForm, LinkMetaRefresh, LinkMeta, LinkTLSSan,
+}
+
+// IsResource returns true if the link is to something the page loads, e.g. a script or
+// image, rather than somewhere it links to.
+func (k LinkKind) IsResource() bool {
+	switch k {
+	case LinkLink, LinkScript, LinkImage, LinkIframe, LinkMeta:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseLinkKind returns an error if s isn't a known LinkKind.
+func ParseLinkKind(s string) (LinkKind, error) {
+	for _, k := range LinkKinds {
+		if string(k) == s {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unknown link source: %s, must be one of %v", s, LinkKinds)
+}
+
+// Color returns the colour visual providers draw edges of this kind in, links between
+// pages are drawn darker than links to resources.
+func (k LinkKind) Color() string {
+	switch k {
+	case LinkAnchor:
+		return "#4a6fa5"
+	case LinkForm, LinkMetaRefresh:
+		return "#7b4aa5"
+	case LinkScript:
+		return "#d08c2f"
+	case LinkLink:
+		return "#3f9f6e"
+	case LinkImage, LinkMeta:
+		return "#c75f8f"
+	case LinkIframe:
+		return "#a5a24a"
+	case LinkRedirect:
+		return "#d04a4a"
+	case LinkTLSSan:
+		return "#2f9fd0"
+	default:
+		return "#999999"
+	}
+}
+
+// Edge is a connection from one hostname to another found in a single page. Links from
+// the page to the same hostname with the same kind are grouped in to one Edge.
+type Edge struct {
+	From, To   string
+	Kind       LinkKind
+	SourceUrl  string   // The page the links were found in.
+	AnchorText string   // From the first link that has any.
+	Rel        []string // Every rel value of the links, e.g. nofollow, sponsored, or ugc.
+	Count      int      // How many links there were.
+}
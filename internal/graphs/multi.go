@@ -0,0 +1,94 @@
+package graphs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Output is a GraphProvider used by Multi, along with the file name (without an
+// extension) it should be rendered to if it's a CliGraphProvider.
+type Output struct {
+	Provider GraphProvider
+	Filename string
+}
+
+// Multi defines a GraphProvider that forwards every connection and notification to any
+// number of other providers, so a single crawl can produce many outputs.
+type Multi struct {
+	outputs []Output
+}
+
+var (
+	_ WebsocketGraphProvider = (*Multi)(nil)
+	_ FetchRecorder          = (*Multi)(nil)
+	_ io.Closer              = (*Multi)(nil)
+)
+
+func NewMulti(outputs ...Output) *Multi {
+	return &Multi{outputs: outputs}
+}
+
+func (m *Multi) AddHostnameConnection(edge Edge) {
+	for _, output := range m.outputs {
+		output.Provider.AddHostnameConnection(edge)
+	}
+}
+
+func (m *Multi) NotifyStartCrawl(workerId uint, hostname string) {
+	for _, output := range m.outputs {
+		if n, ok := output.Provider.(CrawlNotifier); ok {
+			n.NotifyStartCrawl(workerId, hostname)
+		}
+	}
+}
+
+func (m *Multi) NotifyEndCrawl(workerId uint, hostname string, deadEnd bool) {
+	for _, output := range m.outputs {
+		if n, ok := output.Provider.(CrawlNotifier); ok {
+			n.NotifyEndCrawl(workerId, hostname, deadEnd)
+		}
+	}
+}
+
+func (m *Multi) RecordFetch(result FetchResult) {
+	for _, output := range m.outputs {
+		if r, ok := output.Provider.(FetchRecorder); ok {
+			r.RecordFetch(result)
+		}
+	}
+}
+
+// Close closes every provider that is an io.Closer, e.g. database sinks that need to
+// flush their writes.
+func (m *Multi) Close() error {
+	var errs []error
+	for _, output := range m.outputs {
+		if c, ok := output.Provider.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RenderToFiles renders every CliGraphProvider to its own Filename, or to filename if it
+// doesn't have one. Every provider is rendered even if some fail.
+func (m *Multi) RenderToFiles(filename string) error {
+	var errs []error
+	for _, output := range m.outputs {
+		p, ok := output.Provider.(CliGraphProvider)
+		if !ok {
+			continue
+		}
+		name := output.Filename
+		if name == "" {
+			name = filename
+		}
+		if err := RenderToFile(p, name); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,221 @@
+package postgres
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+const (
+	// batchSize is how many records are COPYed in each transaction, Postgres handles
+	// bigger batches better than SQLite.
+	batchSize = 5_000
+	// writeTimeout is how long a single attempt at writing a batch can take.
+	writeTimeout = time.Minute
+	// writeAttempts is how many times a batch is tried before it's dropped, waiting
+	// retryDelay longer each time. The pool replaces any connections that were lost.
+	writeAttempts = 3
+	retryDelay    = time.Second
+)
+
+// Postgres defines a GraphProvider that stores every hostname, connection, and fetch
+// result in a PostgreSQL database under a session ID, see schema.go.
+type Postgres struct {
+	*graphs.RecordBatcher
+	pool    *pgxpool.Pool
+	session string
+
+	closeOnce *sync.Once
+	closeErr  error
+}
+
+var (
+	_ graphs.GraphProvider = (*Postgres)(nil)
+	_ graphs.FetchRecorder = (*Postgres)(nil)
+)
+
+// NewSessionId returns a new unique, roughly time ordered, session ID.
+func NewSessionId() string {
+	random := make([]byte, 4)
+	_, _ = rand.Read(random)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(random)
+}
+
+// NewPostgres connects to the database, creates the schema if needed, and starts
+// writing to the given session. If the session already exists it's resumed and added
+// to. Close must be called to write any queued records.
+func NewPostgres(ctx context.Context, connString, session string) (*Postgres, error) {
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+	// The staging tables are temporary, so every connection needs its own.
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		_, err := conn.Exec(ctx, stagingSchema)
+		return err
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var resumed bool
+	err = func() error {
+		if _, err := pool.Exec(ctx, schema); err != nil {
+			return err
+		}
+		return pool.QueryRow(ctx, `
+			INSERT INTO sessions (id) VALUES ($1)
+			ON CONFLICT (id) DO UPDATE SET resumed_at = now()
+			RETURNING resumed_at IS NOT NULL`,
+			session,
+		).Scan(&resumed)
+	}()
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	if resumed {
+		log.Printf("Resuming Postgres session %s\n", session)
+	} else {
+		log.Printf("Started Postgres session %s\n", session)
+	}
+
+	p := &Postgres{
+		pool:      pool,
+		session:   session,
+		closeOnce: &sync.Once{},
+	}
+	p.RecordBatcher = graphs.NewRecordBatcher(batchSize, p.writeBatch)
+	return p, nil
+}
+
+// Session returns the ID of the session being written to.
+func (p *Postgres) Session() string {
+	return p.session
+}
+
+// Close writes any queued records and closes the connections. It returns the last error
+// from a batch that was dropped, if there was one.
+func (p *Postgres) Close() error {
+	p.closeOnce.Do(func() {
+		p.closeErr = p.RecordBatcher.Close()
+		p.pool.Close()
+	})
+	return p.closeErr
+}
+
+// writeBatch writes batch, retrying it if it fails. Each attempt is a single
+// transaction, so a failed one doesn't leave anything behind.
+func (p *Postgres) writeBatch(batch []graphs.Record) error {
+	var err error
+	for attempt := 1; attempt <= writeAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * retryDelay)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err = p.writeTx(ctx, batch)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		log.Printf("Could not write %d records to Postgres (attempt %d of %d), err: %v\n", len(batch), attempt, writeAttempts, err)
+	}
+	return fmt.Errorf("dropped %d records that couldn't be written to Postgres: %w", len(batch), err)
+}
+
+// rows returns the rows to COPY in to edges_staging and fetches_staging, in the order
+// of their columns in stagingSchema.
+func rows(batch []graphs.Record) (edgeRows, fetchRows [][]any) {
+	for _, r := range batch {
+		if r.Edge != nil {
+			e := r.Edge
+			var anchorText, rel any
+			if e.AnchorText != "" {
+				anchorText = e.AnchorText
+			}
+			if len(e.Rel) > 0 {
+				rel = e.Rel
+			}
+			edgeRows = append(edgeRows, []any{e.From, e.To, string(e.Kind), r.Found, e.SourceUrl, anchorText, rel})
+			continue
+		}
+		f := r.Fetch
+		var status, fetchErr any
+		if f.StatusCode != 0 {
+			status = int32(f.StatusCode)
+		}
+		if f.Err != nil {
+			fetchErr = f.Err.Error()
+		}
+		fetchRows = append(fetchRows, []any{
+			f.Hostname, f.Url, int32(f.WorkerId), f.Started, f.Duration.Milliseconds(), status, int32(f.Links), fetchErr,
+		})
+	}
+	return edgeRows, fetchRows
+}
+
+// writeTx COPYs the batch in to the staging tables and upserts it from there.
+func (p *Postgres) writeTx(ctx context.Context, batch []graphs.Record) error {
+	edgeRows, fetchRows := rows(batch)
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"edges_staging"},
+		[]string{"from_host", "to_host", "kind", "discovered_at", "source_url", "anchor_text", "rel"},
+		pgx.CopyFromRows(edgeRows),
+	); err != nil {
+		return err
+	}
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"fetches_staging"},
+		[]string{"hostname", "url", "worker", "started_at", "duration_ms", "status_code", "links", "error"},
+		pgx.CopyFromRows(fetchRows),
+	); err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		`INSERT INTO hosts (session_id, hostname, discovered_at)
+		SELECT $1::text, hostname, min(discovered_at) FROM (
+			SELECT from_host AS hostname, discovered_at FROM edges_staging
+			UNION ALL SELECT to_host, discovered_at FROM edges_staging
+			UNION ALL SELECT hostname, started_at FROM fetches_staging
+		) AS seen
+		GROUP BY hostname
+		ON CONFLICT (session_id, hostname) DO UPDATE
+		SET discovered_at = least(hosts.discovered_at, excluded.discovered_at)`,
+
+		`INSERT INTO edges (session_id, from_host, to_host, kind, discovered_at, source_url, anchor_text, rel)
+		SELECT DISTINCT ON (from_host, to_host, kind)
+			$1::text, from_host, to_host, kind, discovered_at, source_url, anchor_text, rel
+		FROM edges_staging
+		ORDER BY from_host, to_host, kind, discovered_at
+		ON CONFLICT (session_id, from_host, to_host, kind) DO NOTHING`,
+
+		`INSERT INTO fetches (session_id, hostname, url, worker, started_at, duration_ms, status_code, links, error)
+		SELECT $1::text, hostname, url, worker, started_at, duration_ms, status_code, links, error
+		FROM fetches_staging`,
+	} {
+		if _, err := tx.Exec(ctx, query, p.session); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
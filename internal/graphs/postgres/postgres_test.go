@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// testConnString returns the database to run integration tests against, they're skipped
+// if NOMAD_TEST_POSTGRES isn't set. Each test uses its own session.
+func testConnString(t *testing.T) string {
+	t.Helper()
+	connString := os.Getenv("NOMAD_TEST_POSTGRES")
+	if connString == "" {
+		t.Skip("NOMAD_TEST_POSTGRES isn't set")
+	}
+	return connString
+}
+
+func TestRows(t *testing.T) {
+	found := time.Now()
+	edge := graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor, SourceUrl: "https://a.com/", Rel: []string{"ugc"}}
+	fetch := graphs.FetchResult{Url: "https://a.com", Hostname: "a.com", WorkerId: 2, Started: found, Duration: time.Second, Links: 3, Err: errors.New("oops")}
+
+	edgeRows, fetchRows := rows([]graphs.Record{{Edge: &edge, Found: found}, {Fetch: &fetch}})
+	if len(edgeRows) != 1 || len(fetchRows) != 1 {
+		t.Fatalf("got %d edge rows and %d fetch rows, expected 1 of each", len(edgeRows), len(fetchRows))
+	}
+	// Empty values are NULL.
+	if edgeRows[0][5] != nil {
+		t.Errorf("anchor_text is %v, expected NULL", edgeRows[0][5])
+	}
+	if fetchRows[0][5] != nil || fetchRows[0][7] != "oops" {
+		t.Errorf("status_code and error are %v and %v, expected NULL and oops", fetchRows[0][5], fetchRows[0][7])
+	}
+	if fetchRows[0][4] != int64(1000) {
+		t.Errorf("duration_ms is %v, expected 1000", fetchRows[0][4])
+	}
+}
+
+func TestPostgres(t *testing.T) {
+	connString := testConnString(t)
+	ctx := context.Background()
+	session := NewSessionId()
+
+	p, err := NewPostgres(ctx, connString, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor, SourceUrl: "https://a.com/", AnchorText: "B", Rel: []string{"nofollow", "ugc"}})
+	// Only the first of each connection and kind is kept.
+	p.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor, SourceUrl: "https://a.com/other"})
+	p.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkScript, SourceUrl: "https://a.com/"})
+	p.RecordFetch(graphs.FetchResult{Url: "https://a.com", Hostname: "a.com", WorkerId: 1, Started: time.Now(), StatusCode: 200, Links: 3})
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resuming the session adds to it.
+	p, err = NewPostgres(ctx, connString, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.AddHostnameConnection(graphs.Edge{From: "b.com", To: "c.com", Kind: graphs.LinkAnchor, SourceUrl: "https://b.com/"})
+	p.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor, SourceUrl: "https://a.com/again"})
+	p.RecordFetch(graphs.FetchResult{Url: "https://d.com", Hostname: "d.com", WorkerId: 2, Started: time.Now(), Err: errors.New("timeout")})
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	var resumed bool
+	if err := conn.QueryRow(ctx, "SELECT resumed_at IS NOT NULL FROM sessions WHERE id = $1", session).Scan(&resumed); err != nil {
+		t.Fatal(err)
+	}
+	if !resumed {
+		t.Error("the session wasn't marked as resumed")
+	}
+
+	for _, count := range []struct {
+		query    string
+		expected int
+	}{
+		{"SELECT count(*) FROM hosts WHERE session_id = $1", 4},
+		{"SELECT count(*) FROM edges WHERE session_id = $1", 3},
+		{"SELECT count(*) FROM fetches WHERE session_id = $1", 2},
+		{"SELECT count(*) FROM fetches WHERE session_id = $1 AND status_code IS NULL AND error = 'timeout'", 1},
+	} {
+		var n int
+		if err := conn.QueryRow(ctx, count.query, session).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != count.expected {
+			t.Errorf("%s returned %d, expected %d", count.query, n, count.expected)
+		}
+	}
+
+	var sourceUrl, anchorText string
+	var rel []string
+	err = conn.QueryRow(ctx, `
+		SELECT source_url, anchor_text, rel FROM edges
+		WHERE session_id = $1 AND from_host = 'a.com' AND to_host = 'b.com' AND kind = 'a'`,
+		session,
+	).Scan(&sourceUrl, &anchorText, &rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sourceUrl != "https://a.com/" || anchorText != "B" || len(rel) != 2 {
+		t.Errorf("got the edge %s %q %v, expected the first one found", sourceUrl, anchorText, rel)
+	}
+}
+
+func TestPostgresSessionsAreSeparate(t *testing.T) {
+	connString := testConnString(t)
+	ctx := context.Background()
+
+	sessions := []string{NewSessionId(), NewSessionId()}
+	for _, session := range sessions {
+		p, err := NewPostgres(ctx, connString, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor, SourceUrl: "https://a.com/"})
+		if err := p.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	for _, session := range sessions {
+		var n int
+		if err := conn.QueryRow(ctx, "SELECT count(*) FROM edges WHERE session_id = $1", session).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("session %s has %d edges, expected 1", session, n)
+		}
+	}
+}
+
+func TestPostgresReconnects(t *testing.T) {
+	connString := testConnString(t)
+	ctx := context.Background()
+	session := NewSessionId()
+
+	p, err := NewPostgres(ctx, connString, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Close the pool's connections underneath it, as if the server had restarted.
+	for _, conn := range p.pool.AcquireAllIdle(ctx) {
+		conn.Conn().PgConn().Close(ctx)
+		conn.Release()
+	}
+	p.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor, SourceUrl: "https://a.com/"})
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM edges WHERE session_id = $1", session).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d edges, expected the one written after reconnecting", n)
+	}
+}
+
+func TestPostgresCloseReturnsDroppedBatch(t *testing.T) {
+	connString := testConnString(t)
+
+	p, err := NewPostgres(context.Background(), connString, NewSessionId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every attempt fails once the pool is closed.
+	p.pool.Close()
+	p.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor, SourceUrl: "https://a.com/"})
+	if err := p.Close(); err == nil {
+		t.Error("Close didn't return the error from the dropped batch")
+	}
+}
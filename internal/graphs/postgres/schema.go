@@ -0,0 +1,82 @@
+package postgres
+
+// schema is created if it doesn't already exist. Every row belongs to a crawl session,
+// so many crawls can share a database and a session can be resumed by reusing its ID.
+//
+//   - sessions has a row for every session, resumed_at is set each time it's resumed.
+//   - hosts has a row for every hostname seen in a session, crawled or not.
+//   - edges has a row for every unique directed connection between two hosts and kind
+//     of link (see graphs.LinkKind) in a session, with the page, anchor text, and rel
+//     values of the links it was first seen in. The index on (to_host, from_host) suits
+//     "who links to X" queries.
+//   - fetches has a row for every URL fetched. status_code is NULL if there was no
+//     response, and error is NULL if the fetch succeeded.
+//
+// The staging tables are per connection and emptied on commit, batches are COPYed in to
+// them and then upserted in to the real tables.
+const schema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id         TEXT        PRIMARY KEY,
+	started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	resumed_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS hosts (
+	session_id    TEXT        NOT NULL REFERENCES sessions (id),
+	hostname      TEXT        NOT NULL,
+	discovered_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (session_id, hostname)
+);
+
+CREATE TABLE IF NOT EXISTS edges (
+	session_id    TEXT        NOT NULL REFERENCES sessions (id),
+	from_host     TEXT        NOT NULL,
+	to_host       TEXT        NOT NULL,
+	kind          TEXT        NOT NULL,
+	discovered_at TIMESTAMPTZ NOT NULL,
+	source_url    TEXT        NOT NULL,
+	anchor_text   TEXT,
+	rel           TEXT[],
+	PRIMARY KEY (session_id, from_host, to_host, kind)
+);
+
+CREATE INDEX IF NOT EXISTS edges_to_host ON edges (session_id, to_host, from_host);
+
+CREATE TABLE IF NOT EXISTS fetches (
+	id          BIGSERIAL   PRIMARY KEY,
+	session_id  TEXT        NOT NULL REFERENCES sessions (id),
+	hostname    TEXT        NOT NULL,
+	url         TEXT        NOT NULL,
+	worker      INTEGER     NOT NULL,
+	started_at  TIMESTAMPTZ NOT NULL,
+	duration_ms BIGINT      NOT NULL,
+	status_code INTEGER,
+	links       INTEGER     NOT NULL,
+	error       TEXT
+);
+
+CREATE INDEX IF NOT EXISTS fetches_hostname ON fetches (session_id, hostname, started_at);
+`
+
+const stagingSchema = `
+CREATE TEMPORARY TABLE IF NOT EXISTS edges_staging (
+	from_host     TEXT        NOT NULL,
+	to_host       TEXT        NOT NULL,
+	kind          TEXT        NOT NULL,
+	discovered_at TIMESTAMPTZ NOT NULL,
+	source_url    TEXT        NOT NULL,
+	anchor_text   TEXT,
+	rel           TEXT[]
+) ON COMMIT DELETE ROWS;
+
+CREATE TEMPORARY TABLE IF NOT EXISTS fetches_staging (
+	hostname    TEXT        NOT NULL,
+	url         TEXT        NOT NULL,
+	worker      INTEGER     NOT NULL,
+	started_at  TIMESTAMPTZ NOT NULL,
+	duration_ms BIGINT      NOT NULL,
+	status_code INTEGER,
+	links       INTEGER     NOT NULL,
+	error       TEXT
+) ON COMMIT DELETE ROWS;
+`
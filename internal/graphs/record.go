@@ -0,0 +1,53 @@
+package graphs
+
+import (
+	"time"
+
+	. "github.com/psidex/nomad/internal/lib"
+)
+
+// Records are written in batches at least every recordFlushInterval, and callers only
+// block if recordQueueBatches batches are already waiting.
+const (
+	recordFlushInterval = time.Second
+	recordQueueBatches  = 10
+)
+
+// Record is a connection or fetch result waiting to be written by a database provider,
+// only one of Edge and Fetch is set.
+type Record struct {
+	Edge  *Edge
+	Found time.Time // When Edge was found.
+	Fetch *FetchResult
+}
+
+// RecordBatcher queues the connections and fetch results given to a database provider
+// and writes them in batches from a single goroutine. Providers embed it to implement
+// GraphProvider and FetchRecorder.
+type RecordBatcher struct {
+	batcher *Batcher[Record]
+}
+
+// NewRecordBatcher creates a RecordBatcher that calls write with up to batchSize
+// records at a time.
+func NewRecordBatcher(batchSize int, write func([]Record) error) *RecordBatcher {
+	return &RecordBatcher{
+		batcher: NewBatcher(batchSize, recordFlushInterval, batchSize*recordQueueBatches, write),
+	}
+}
+
+// AddHostnameConnection must not be called after Close.
+func (b *RecordBatcher) AddHostnameConnection(edge Edge) {
+	b.batcher.Add(Record{Edge: &edge, Found: time.Now()})
+}
+
+// RecordFetch must not be called after Close.
+func (b *RecordBatcher) RecordFetch(result FetchResult) {
+	b.batcher.Add(Record{Fetch: &result})
+}
+
+// Close writes any queued records, returning the last error from writing if there was
+// one.
+func (b *RecordBatcher) Close() error {
+	return b.batcher.Close()
+}
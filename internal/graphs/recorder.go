@@ -0,0 +1,171 @@
+package graphs
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AttributeType is the type of an Attribute's values.
+type AttributeType string
+
+const (
+	AttributeString  AttributeType = "string"
+	AttributeBoolean AttributeType = "boolean"
+	AttributeInteger AttributeType = "integer"
+)
+
+// Attribute describes a value that file formats store for each node or edge.
+type Attribute struct {
+	ID    string
+	Title string
+	Type  AttributeType
+}
+
+// NodeAttributes are the attributes of a RecordedNode, in the order of its
+// AttributeValues.
+var NodeAttributes = []Attribute{
+	{ID: "crawled", Title: "crawled", Type: AttributeBoolean},
+	{ID: "deadEnd", Title: "dead end", Type: AttributeBoolean},
+	{ID: "indegree", Title: "indegree", Type: AttributeInteger},
+	{ID: "outdegree", Title: "outdegree", Type: AttributeInteger},
+}
+
+// EdgeAttributes are the attributes of a RecordedEdge, in the order of its
+// AttributeValues.
+var EdgeAttributes = []Attribute{
+	{ID: "kind", Title: "kind", Type: AttributeString},
+	{ID: "sourceUrl", Title: "source URL", Type: AttributeString},
+	{ID: "anchorText", Title: "anchor text", Type: AttributeString},
+	{ID: "rel", Title: "rel", Type: AttributeString},
+}
+
+// RecordedNode is a hostname as seen by a Recorder. InDegree and OutDegree count the
+// hostnames it's connected to, not the kinds of connection.
+type RecordedNode struct {
+	Hostname   string
+	Discovered time.Time
+	Crawled    bool
+	DeadEnd    bool
+	InDegree   int
+	OutDegree  int
+}
+
+// AttributeValues returns the value of each of NodeAttributes.
+func (n RecordedNode) AttributeValues() []string {
+	return []string{
+		strconv.FormatBool(n.Crawled),
+		strconv.FormatBool(n.DeadEnd),
+		strconv.Itoa(n.InDegree),
+		strconv.Itoa(n.OutDegree),
+	}
+}
+
+// RecordedEdge is a connection between two hostnames as seen by a Recorder. SourceUrl,
+// AnchorText, and Rel are from the first Edge that was found.
+type RecordedEdge struct {
+	From       int // Index of the RecordedNode.
+	To         int
+	Kind       LinkKind
+	SourceUrl  string
+	AnchorText string
+	Rel        []string
+	Discovered time.Time
+}
+
+// AttributeValues returns the value of each of EdgeAttributes.
+func (e RecordedEdge) AttributeValues() []string {
+	return []string{string(e.Kind), e.SourceUrl, e.AnchorText, strings.Join(e.Rel, " ")}
+}
+
+type edgeKey struct {
+	from, to int
+	kind     LinkKind
+}
+
+// Recorder keeps every hostname and unique directed connection and kind in the order
+// they were discovered, for providers that write a whole file format at once. Self
+// connections are ignored.
+type Recorder struct {
+	mu        *sync.Mutex
+	nodes     []RecordedNode
+	nodeIndex map[string]int
+	edges     []RecordedEdge
+	edgeSet   map[edgeKey]struct{}
+	// connected is every pair of connected nodes, regardless of kind.
+	connected map[[2]int]struct{}
+}
+
+var _ CrawlNotifier = (*Recorder)(nil)
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		mu:        &sync.Mutex{},
+		nodeIndex: make(map[string]int),
+		edgeSet:   make(map[edgeKey]struct{}),
+		connected: make(map[[2]int]struct{}),
+	}
+}
+
+// index returns the index of the hostname's node, adding it if it's new.
+func (r *Recorder) index(hostname string, now time.Time) int {
+	i, ok := r.nodeIndex[hostname]
+	if !ok {
+		i = len(r.nodes)
+		r.nodes = append(r.nodes, RecordedNode{Hostname: hostname, Discovered: now})
+		r.nodeIndex[hostname] = i
+	}
+	return i
+}
+
+func (r *Recorder) AddHostnameConnection(edge Edge) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	from := r.index(edge.From, now)
+	to := r.index(edge.To, now)
+
+	key := edgeKey{from: from, to: to, kind: edge.Kind}
+	if _, ok := r.edgeSet[key]; ok || from == to {
+		return
+	}
+	r.edgeSet[key] = struct{}{}
+	// Hostnames can be connected by more than one kind of link, only count them once.
+	if _, ok := r.connected[[2]int{from, to}]; !ok {
+		r.connected[[2]int{from, to}] = struct{}{}
+		r.nodes[from].OutDegree++
+		r.nodes[to].InDegree++
+	}
+	r.edges = append(r.edges, RecordedEdge{
+		From:       from,
+		To:         to,
+		Kind:       edge.Kind,
+		SourceUrl:  edge.SourceUrl,
+		AnchorText: edge.AnchorText,
+		Rel:        edge.Rel,
+		Discovered: now,
+	})
+}
+
+func (r *Recorder) NotifyStartCrawl(workerId uint, hostname string) {}
+
+func (r *Recorder) NotifyEndCrawl(workerId uint, hostname string, deadEnd bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(hostname, time.Now())
+	r.nodes[i].Crawled = true
+	r.nodes[i].DeadEnd = deadEnd
+}
+
+// Snapshot returns a copy of the nodes and edges recorded so far.
+func (r *Recorder) Snapshot() ([]RecordedNode, []RecordedEdge) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nodes := make([]RecordedNode, len(r.nodes))
+	copy(nodes, r.nodes)
+	edges := make([]RecordedEdge, len(r.edges))
+	copy(edges, r.edges)
+	return nodes, edges
+}
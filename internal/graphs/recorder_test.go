@@ -0,0 +1,33 @@
+package graphs
+
+import "testing"
+
+func TestRecorderDegrees(t *testing.T) {
+	r := NewRecorder()
+	r.AddHostnameConnection(Edge{From: "a.com", To: "b.com", Kind: LinkAnchor})
+	// Another kind of link between the same hostnames isn't another connection.
+	r.AddHostnameConnection(Edge{From: "a.com", To: "b.com", Kind: LinkScript})
+	r.AddHostnameConnection(Edge{From: "a.com", To: "b.com", Kind: LinkAnchor})
+	r.AddHostnameConnection(Edge{From: "a.com", To: "c.com", Kind: LinkAnchor})
+	r.AddHostnameConnection(Edge{From: "c.com", To: "a.com", Kind: LinkAnchor})
+	r.AddHostnameConnection(Edge{From: "c.com", To: "c.com", Kind: LinkAnchor})
+
+	nodes, edges := r.Snapshot()
+	if len(edges) != 4 {
+		t.Errorf("got %d edges, expected 4", len(edges))
+	}
+
+	expected := map[string][2]int{
+		"a.com": {1, 2},
+		"b.com": {1, 0},
+		"c.com": {1, 1},
+	}
+	if len(nodes) != len(expected) {
+		t.Fatalf("got %d nodes, expected %d", len(nodes), len(expected))
+	}
+	for _, n := range nodes {
+		if degree := [2]int{n.InDegree, n.OutDegree}; degree != expected[n.Hostname] {
+			t.Errorf("%s has in and out degree %v, expected %v", n.Hostname, degree, expected[n.Hostname])
+		}
+	}
+}
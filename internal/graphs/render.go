@@ -0,0 +1,52 @@
+package graphs
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+)
+
+// StdoutFilename can be given to RenderToFile to render to stdout instead.
+const StdoutFilename = "-"
+
+// RenderToFile atomically renders p to filename plus p's extension, so it can be called
+// repeatedly during a crawl without anything reading the file seeing it half written.
+func RenderToFile(p CliGraphProvider, filename string) error {
+	if filename == StdoutFilename {
+		w := bufio.NewWriter(os.Stdout)
+		if err := p.Render(w); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	filename = filename + p.Extension()
+
+	// Write to a temporary file in the same directory and then rename it over the top of
+	// the previous render.
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := p.Render(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	// CreateTemp only gives the owner access.
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
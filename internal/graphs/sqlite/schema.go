@@ -0,0 +1,54 @@
+package sqlite
+
+// schema is created if it doesn't already exist, so a database can be written to by
+// many crawls. All timestamps are Unix milliseconds.
+//
+//   - hosts has a row for every hostname seen, crawled or not.
+//   - edges has a row for every unique directed connection between two hosts and kind
+//     of link (see graphs.LinkKind), with the time, page, anchor text, and space
+//     separated rel values of the links it was first seen in. anchor_text and rel are
+//     NULL if the links didn't have any. The index on (to_host, from_host) makes "who
+//     links to X" queries a range scan.
+//   - fetches has a row for every URL fetched. status_code is NULL if there was no
+//     response, and error is NULL if the fetch succeeded.
+//
+// For example, to find the hosts linking to example.com:
+//
+//	SELECT f.hostname FROM edges e
+//	JOIN hosts f ON f.id = e.from_host
+//	JOIN hosts t ON t.id = e.to_host
+//	WHERE t.hostname = 'example.com' AND e.kind = 'a';
+const schema = `
+CREATE TABLE IF NOT EXISTS hosts (
+	id            INTEGER PRIMARY KEY,
+	hostname      TEXT    NOT NULL UNIQUE,
+	discovered_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS edges (
+	from_host     INTEGER NOT NULL REFERENCES hosts (id),
+	to_host       INTEGER NOT NULL REFERENCES hosts (id),
+	kind          TEXT    NOT NULL,
+	discovered_at INTEGER NOT NULL,
+	source_url    TEXT    NOT NULL,
+	anchor_text   TEXT,
+	rel           TEXT,
+	PRIMARY KEY (from_host, to_host, kind)
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS edges_to_host ON edges (to_host, from_host);
+
+CREATE TABLE IF NOT EXISTS fetches (
+	id          INTEGER PRIMARY KEY,
+	host        INTEGER NOT NULL REFERENCES hosts (id),
+	url         TEXT    NOT NULL,
+	worker      INTEGER NOT NULL,
+	started_at  INTEGER NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	status_code INTEGER,
+	links       INTEGER NOT NULL,
+	error       TEXT
+);
+
+CREATE INDEX IF NOT EXISTS fetches_host ON fetches (host, started_at);
+`
@@ -0,0 +1,190 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// batchSize is how many records are written in each transaction.
+const batchSize = 1_000
+
+// SQLite defines a GraphProvider that stores every hostname, connection, and fetch
+// result in an SQLite database, see schema.go. An existing database is added to.
+type SQLite struct {
+	*graphs.RecordBatcher
+	db *sql.DB
+	// This is only used by the batcher's goroutine.
+	hostIds map[string]int64
+
+	closeOnce *sync.Once
+	closeErr  error
+}
+
+var (
+	_ graphs.GraphProvider = (*SQLite)(nil)
+	_ graphs.FetchRecorder = (*SQLite)(nil)
+)
+
+// NewSQLite opens or creates the database at path and starts writing to it. Close must
+// be called to write any queued records.
+func NewSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only supports a single writer anyway.
+	db.SetMaxOpenConns(1)
+
+	for _, statement := range []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA synchronous = NORMAL",
+		"PRAGMA foreign_keys = ON",
+		schema,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	s := &SQLite{
+		db:        db,
+		hostIds:   make(map[string]int64),
+		closeOnce: &sync.Once{},
+	}
+	s.RecordBatcher = graphs.NewRecordBatcher(batchSize, s.writeBatch)
+	return s, nil
+}
+
+// Close writes any queued records and closes the database. It returns the last error
+// from writing, if there was one.
+func (s *SQLite) Close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = errors.Join(s.RecordBatcher.Close(), s.db.Close())
+	})
+	return s.closeErr
+}
+
+// batchWriter writes a single batch in a transaction.
+type batchWriter struct {
+	insertHost, selectHost, insertEdge, insertFetch *sql.Stmt
+	cached                                          map[string]int64
+	// New IDs are only cached once the transaction commits.
+	newIds map[string]int64
+}
+
+// hostId returns the ID of hostname, inserting it if it's new.
+func (b *batchWriter) hostId(hostname string, found time.Time) (int64, error) {
+	if id, ok := b.cached[hostname]; ok {
+		return id, nil
+	}
+	if id, ok := b.newIds[hostname]; ok {
+		return id, nil
+	}
+	if _, err := b.insertHost.Exec(hostname, found.UnixMilli()); err != nil {
+		return 0, err
+	}
+	var id int64
+	if err := b.selectHost.QueryRow(hostname).Scan(&id); err != nil {
+		return 0, err
+	}
+	b.newIds[hostname] = id
+	return id, nil
+}
+
+func (b *batchWriter) write(r graphs.Record) error {
+	if r.Edge != nil {
+		e := r.Edge
+		from, err := b.hostId(e.From, r.Found)
+		if err != nil {
+			return err
+		}
+		to, err := b.hostId(e.To, r.Found)
+		if err != nil {
+			return err
+		}
+		_, err = b.insertEdge.Exec(
+			from, to, string(e.Kind), r.Found.UnixMilli(), e.SourceUrl, nullString(e.AnchorText), nullString(strings.Join(e.Rel, " ")),
+		)
+		return err
+	}
+
+	f := r.Fetch
+	host, err := b.hostId(f.Hostname, f.Started)
+	if err != nil {
+		return err
+	}
+	var status, fetchErr any
+	if f.StatusCode != 0 {
+		status = f.StatusCode
+	}
+	if f.Err != nil {
+		fetchErr = f.Err.Error()
+	}
+	_, err = b.insertFetch.Exec(
+		host, f.Url, f.WorkerId, f.Started.UnixMilli(), f.Duration.Milliseconds(), status, f.Links, fetchErr,
+	)
+	return err
+}
+
+// nullString returns nil for "" so it's stored as NULL.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (s *SQLite) writeBatch(batch []graphs.Record) error {
+	err := s.writeTx(batch)
+	if err != nil {
+		log.Printf("Could not write %d records to SQLite, err: %v\n", len(batch), err)
+	}
+	return err
+}
+
+func (s *SQLite) writeTx(batch []graphs.Record) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	b := &batchWriter{cached: s.hostIds, newIds: make(map[string]int64)}
+	for _, prepared := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&b.insertHost, "INSERT INTO hosts (hostname, discovered_at) VALUES (?, ?) ON CONFLICT (hostname) DO NOTHING"},
+		{&b.selectHost, "SELECT id FROM hosts WHERE hostname = ?"},
+		{&b.insertEdge, "INSERT INTO edges (from_host, to_host, kind, discovered_at, source_url, anchor_text, rel) VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING"},
+		{&b.insertFetch, "INSERT INTO fetches (host, url, worker, started_at, duration_ms, status_code, links, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"},
+	} {
+		if *prepared.stmt, err = tx.Prepare(prepared.query); err != nil {
+			return err
+		}
+		defer (*prepared.stmt).Close()
+	}
+
+	for _, r := range batch {
+		if err := b.write(r); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for hostname, id := range b.newIds {
+		s.hostIds[hostname] = id
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+func TestSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nomad.db")
+
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor, SourceUrl: "https://a.com/", AnchorText: "B", Rel: []string{"nofollow", "ugc"}})
+	// Only the first of each connection and kind is kept.
+	s.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkAnchor, SourceUrl: "https://a.com/other"})
+	s.AddHostnameConnection(graphs.Edge{From: "a.com", To: "b.com", Kind: graphs.LinkScript, SourceUrl: "https://a.com/"})
+	s.RecordFetch(graphs.FetchResult{Url: "https://a.com", Hostname: "a.com", WorkerId: 1, Started: time.Now(), StatusCode: 200, Links: 3})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An existing database is added to.
+	s, err = NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddHostnameConnection(graphs.Edge{From: "b.com", To: "c.com", Kind: graphs.LinkAnchor, SourceUrl: "https://b.com/"})
+	s.RecordFetch(graphs.FetchResult{Url: "https://d.com", Hostname: "d.com", WorkerId: 2, Started: time.Now(), Err: errors.New("timeout")})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, count := range []struct {
+		query    string
+		expected int
+	}{
+		{"SELECT count(*) FROM hosts", 4},
+		{"SELECT count(*) FROM edges", 3},
+		{"SELECT count(*) FROM fetches", 2},
+		{"SELECT count(*) FROM fetches WHERE status_code IS NULL AND error = 'timeout'", 1},
+	} {
+		var n int
+		if err := db.QueryRow(count.query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != count.expected {
+			t.Errorf("%s returned %d, expected %d", count.query, n, count.expected)
+		}
+	}
+
+	var sourceUrl, anchorText, rel string
+	err = db.QueryRow(`
+		SELECT e.source_url, e.anchor_text, e.rel FROM edges e
+		JOIN hosts f ON f.id = e.from_host
+		JOIN hosts t ON t.id = e.to_host
+		WHERE f.hostname = 'a.com' AND t.hostname = 'b.com' AND e.kind = 'a'`,
+	).Scan(&sourceUrl, &anchorText, &rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sourceUrl != "https://a.com/" || anchorText != "B" || rel != "nofollow ugc" {
+		t.Errorf("got the edge %s %q %q, expected the first one found", sourceUrl, anchorText, rel)
+	}
+}
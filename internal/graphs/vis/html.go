@@ -1,7 +1,14 @@
 package vis
 
-// TODO: Tidy, add options to JS?
+const cdnScript = `<script type="text/javascript"
+      src="https://unpkg.com/vis-network/standalone/umd/vis-network.min.js"></script>`
 
+// eventsMarker is formatted in to html in place of the events, which are then streamed
+// in to the file in its place.
+const eventsMarker = "/* nomad events */"
+
+// The first %s is the vis.js script tag, the second is the list of events. Any literal
+// percent signs have to be escaped as %%.
 var html = `<!DOCTYPE html>
 <html lang="en">
   <head>
@@ -10,26 +17,68 @@ var html = `<!DOCTYPE html>
         * {
             margin: 0;
         }
+        body {
+            font-family: sans-serif;
+        }
         #mynetwork {
             width: 100vw;
             height: 100vh;
         }
+        #controls {
+            position: fixed;
+            left: 0;
+            right: 0;
+            bottom: 0;
+            display: flex;
+            gap: 10px;
+            align-items: center;
+            padding: 8px 12px;
+            background: rgba(255, 255, 255, 0.9);
+            border-top: 1px solid #ddd;
+        }
+        #scrub {
+            flex: 1;
+        }
     </style>
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <script type="text/javascript"
-      src="https://unpkg.com/vis-network/standalone/umd/vis-network.min.js"></script>
+    %s
   </head>
   <body>
     <div id="mynetwork"></div>
+    <div id="controls">
+      <button id="play">Pause</button>
+      <label>Speed
+        <select id="speed">
+          <option value="0.25">0.25x</option>
+          <option value="0.5">0.5x</option>
+          <option value="1" selected>1x</option>
+          <option value="2">2x</option>
+          <option value="4">4x</option>
+          <option value="8">8x</option>
+          <option value="16">16x</option>
+        </select>
+      </label>
+      <label>Timing
+        <select id="timing">
+          <option value="uniform" selected>Uniform</option>
+          <option value="real">Real</option>
+        </select>
+      </label>
+      <input id="scrub" type="range" min="0" value="0">
+      <span id="status"></span>
+    </div>
     <script type="text/javascript">
 let nodesAndEdges = [%s
 ];
 
+// Milliseconds between each event when using uniform timing at 1x.
+const uniformDelay = 50;
+
 var container = document.getElementById("mynetwork");
 
 var data = {
-  nodes: [],
-  edges: [],
+  nodes: new vis.DataSet(),
+  edges: new vis.DataSet(),
 };
 
 var options = {
@@ -46,26 +95,99 @@ var options = {
 };
 var network = new vis.Network(container, data, options);
 
-let index = 0;
+const playButton = document.getElementById("play");
+const speedSelect = document.getElementById("speed");
+const timingSelect = document.getElementById("timing");
+const scrub = document.getElementById("scrub");
+const statusText = document.getElementById("status");
 
-function addItem() {
-    if (index < nodesAndEdges.length) {
-        const item = nodesAndEdges[index];
-        const dataType = item.type;
+const startTime = nodesAndEdges.length > 0 ? nodesAndEdges[0].time : 0;
+scrub.max = nodesAndEdges.length;
 
-        if (dataType === "node") {
-            network.body.data.nodes.add(item.data);
-        } else if (dataType === "edge") {
-            network.body.data.edges.add(item.data);
+// index is the number of events that are currently shown.
+let index = 0;
+let playing = true;
+let timer = null;
+
+function updateStatus() {
+    scrub.value = index;
+    let text = index + " / " + nodesAndEdges.length;
+    if (index > 0) {
+        const item = nodesAndEdges[index - 1];
+        text += ", +" + ((item.time - startTime) / 1000).toFixed(1) + "s";
+        if (item.worker !== null) {
+            text += ", worker " + item.worker;
         }
+    }
+    statusText.textContent = text;
+}
+
+function addItems(items) {
+    const nodes = items.filter((item) => item.type === "node").map((item) => item.data);
+    const edges = items.filter((item) => item.type === "edge").map((item) => item.data);
+    data.nodes.add(nodes);
+    data.edges.add(edges);
+}
 
-        index++;
-        setTimeout(addItem, 50); // milliseconds
+// seek shows exactly the first target events.
+function seek(target) {
+    if (target < index) {
+        data.edges.clear();
+        data.nodes.clear();
+        index = 0;
     }
+    addItems(nodesAndEdges.slice(index, target));
+    index = target;
+    updateStatus();
 }
 
-// Call the function to start the iteration
-addItem();
+function nextDelay() {
+    const speed = parseFloat(speedSelect.value);
+    if (timingSelect.value === "real" && index > 0) {
+        return Math.max(0, nodesAndEdges[index].time - nodesAndEdges[index - 1].time) / speed;
+    }
+    return uniformDelay / speed;
+}
+
+function schedule() {
+    clearTimeout(timer);
+    if (index >= nodesAndEdges.length) {
+        // Pause at the end so pressing play starts again.
+        playing = false;
+        playButton.textContent = "Play";
+        return;
+    }
+    if (!playing) {
+        return;
+    }
+    timer = setTimeout(() => {
+        seek(index + 1);
+        schedule();
+    }, nextDelay());
+}
+
+function setPlaying(value) {
+    playing = value;
+    playButton.textContent = playing ? "Pause" : "Play";
+    schedule();
+}
+
+playButton.addEventListener("click", () => {
+    // Playing from the end starts again.
+    if (!playing && index >= nodesAndEdges.length) {
+        seek(0);
+    }
+    setPlaying(!playing);
+});
+speedSelect.addEventListener("change", schedule);
+timingSelect.addEventListener("change", schedule);
+scrub.addEventListener("input", () => {
+    seek(parseInt(scrub.value, 10));
+    schedule();
+});
+
+updateStatus();
+schedule();
         </script>
   </body>
 </html>`
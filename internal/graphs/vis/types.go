@@ -6,24 +6,31 @@ type nodeData struct {
 }
 
 type node struct {
-	Type string   `json:"type"` // always "node"
-	Data nodeData `json:"data"`
+	Type   string   `json:"type"`   // always "node"
+	Time   int64    `json:"time"`   // Unix milliseconds
+	Worker *uint    `json:"worker"` // The worker that found it, null if unknown
+	Data   nodeData `json:"data"`
 }
 
-func newNode() node {
-	return node{Type: "node"}
+func newNode(time int64, worker *uint) node {
+	return node{Type: "node", Time: time, Worker: worker}
 }
 
 type edgeData struct {
-	From int `json:"from"`
-	To   int `json:"to"`
+	From   int    `json:"from"`
+	To     int    `json:"to"`
+	Color  string `json:"color"`
+	Dashes bool   `json:"dashes"`
+	Title  string `json:"title"` // Shown on hover.
 }
 
 type edge struct {
-	Type string   `json:"type"` // always "edge"
-	Data edgeData `json:"data"`
+	Type   string   `json:"type"` // always "edge"
+	Time   int64    `json:"time"`
+	Worker *uint    `json:"worker"`
+	Data   edgeData `json:"data"`
 }
 
-func newEdge() edge {
-	return edge{Type: "edge"}
+func newEdge(time int64, worker *uint) edge {
+	return edge{Type: "edge", Time: time, Worker: worker}
 }
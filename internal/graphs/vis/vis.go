@@ -3,108 +3,182 @@ package vis
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"io"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/psidex/nomad/internal/graphs"
+	"github.com/psidex/nomad/internal/graphs/assets"
 	. "github.com/psidex/nomad/internal/lib"
 )
 
+// Options configures the HTML output.
+type Options struct {
+	// Offline embeds vis.js in the HTML instead of loading it from a CDN.
+	Offline bool `json:"offline"`
+}
+
+// event is a node or edge being added, in the order they were found.
+type event struct {
+	time   int64 // Unix milliseconds
+	worker *uint
+	isEdge bool
+	from   int             // The node's ID, or the edge's source.
+	to     int             // The edge's target.
+	label  string          // The node's hostname, or the edge's anchor text.
+	kind   graphs.LinkKind // The edge's kind.
+}
+
 // Vis defines a CliGraphProvider that renders to a HTML file which "replays" the crawl
-// using vis.js.
+// using vis.js. Every node and edge is recorded with the time it was found and the
+// worker that found it, so the replay can follow the crawl's real timing.
 type Vis struct {
+	options   Options
 	mu        *sync.Mutex
 	hasher    *StrHasher
-	seenNodes Set
-	seenEdges Set
-	output    string
+	seenNodes map[int]struct{}
+	seenEdges map[[2]int]struct{}
+	crawling  map[string]uint // Hostname -> the ID of the worker crawling it.
+	events    []event
 }
 
-var _ graphs.CliGraphProvider = (*Vis)(nil)
+var (
+	_ graphs.CliGraphProvider = (*Vis)(nil)
+	_ graphs.CrawlNotifier    = (*Vis)(nil)
+)
 
-func NewVis() *Vis {
+func NewVis(options Options) *Vis {
 	return &Vis{
+		options:   options,
 		mu:        &sync.Mutex{},
 		hasher:    NewStrHasher(),
-		seenNodes: NewSet(),
-		seenEdges: NewSet(),
-		output:    "",
+		seenNodes: make(map[int]struct{}),
+		seenEdges: make(map[[2]int]struct{}),
+		crawling:  make(map[string]uint),
 	}
 }
 
-// FIXME: This algorithm is quite inefficient:
-//   - Renders JSON before checking if it needs to
-//   - Stores rendered JSON in the node & edge sets
-//   - Doesn't check inverse edges before adding to output
+func (v *Vis) NotifyStartCrawl(workerId uint, hostname string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.crawling[hostname] = workerId
+}
 
-func (v *Vis) AddHostnameConnection(fromHost, toHost string) {
+func (v *Vis) NotifyEndCrawl(workerId uint, hostname string, deadEnd bool) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
+	if v.crawling[hostname] == workerId {
+		delete(v.crawling, hostname)
+	}
+}
 
-	// Get the unique ID for this host.
-	fromHostId := v.hasher.Hash(fromHost)
+// addNode records a node event if the hostname hasn't been seen before.
+func (v *Vis) addNode(hostname string, time int64, worker *uint) int {
+	id := v.hasher.Hash(hostname)
+	if _, ok := v.seenNodes[id]; !ok {
+		v.seenNodes[id] = struct{}{}
+		v.events = append(v.events, event{time: time, worker: worker, from: id, label: hostname})
+	}
+	return id
+}
 
-	// Create the data structure to be marshalled to JSON.
-	fromHostNode := newNode()
-	fromHostNode.Data = nodeData{ID: fromHostId, Label: fromHost}
+func (v *Vis) AddHostnameConnection(connection graphs.Edge) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 
-	// Marshall to JSON and get that as a string.
-	fromHostNodeJson, err := json.Marshal(fromHostNode)
-	if err != nil {
-		// For now, if we encounter an error, just abandon this process.
-		return
-	}
-	fromHostNodeJsonStr := string(fromHostNodeJson)
+	fromHost, toHost := connection.From, connection.To
 
-	// If we haven't already seen this JSON.
-	if !v.seenNodes.Contains(fromHostNodeJsonStr) {
-		// Remember that we've seen it, and add it to our output.
-		v.seenNodes.Add(fromHostNodeJsonStr)
-		v.output += fmt.Sprintf("\n%s,", fromHostNodeJsonStr)
+	now := time.Now().UnixMilli()
+
+	// The connection was found by whichever worker is crawling fromHost, Nomad adds a
+	// hostname's connections before notifying that its crawl has ended.
+	var worker *uint
+	if id, ok := v.crawling[fromHost]; ok {
+		worker = &id
 	}
 
-	toHostId := v.hasher.Hash(toHost)
-	toHostNode := newNode()
-	toHostNode.Data = nodeData{ID: toHostId, Label: toHost}
-	toHostNodeJson, err := json.Marshal(toHostNode)
-	if err != nil {
+	fromHostId := v.addNode(fromHost, now, worker)
+	toHostId := v.addNode(toHost, now, worker)
+
+	// The edges aren't drawn with a direction, so the inverse edge is the same edge. It's
+	// styled by the kind of the first link found.
+	edge := [2]int{fromHostId, toHostId}
+	inverseEdge := [2]int{toHostId, fromHostId}
+	if _, ok := v.seenEdges[edge]; ok {
 		return
 	}
-	toHostNodeJsonStr := string(toHostNodeJson)
-	if !v.seenNodes.Contains(toHostNodeJsonStr) {
-		v.seenNodes.Add(toHostNodeJsonStr)
-		v.output += fmt.Sprintf("\n%s,", toHostNodeJsonStr)
-	}
-
-	edge := newEdge()
-	edge.Data = edgeData{From: fromHostId, To: toHostId}
-	edgeJson, err := json.Marshal(edge)
-	if err != nil {
+	if _, ok := v.seenEdges[inverseEdge]; ok {
 		return
 	}
-	edgeJsonStr := string(edgeJson)
-	if !v.seenEdges.Contains(edgeJsonStr) {
-		v.seenEdges.Add(edgeJsonStr)
-		v.output += fmt.Sprintf("\n%s,", edgeJsonStr)
+	v.seenEdges[edge] = struct{}{}
+	v.events = append(v.events, event{
+		time: now, worker: worker, isEdge: true, from: fromHostId, to: toHostId,
+		label: connection.AnchorText, kind: connection.Kind,
+	})
+}
+
+// writeEvents writes each event as JSON on a new line followed by a comma, to be placed
+// in a JS array.
+func writeEvents(w io.Writer, events []event) error {
+	for _, e := range events {
+		var item any
+		if e.isEdge {
+			edge := newEdge(e.time, e.worker)
+			title := string(e.kind)
+			if e.label != "" {
+				title += ": " + e.label
+			}
+			edge.Data = edgeData{
+				From:   e.from,
+				To:     e.to,
+				Color:  e.kind.Color(),
+				Dashes: e.kind.IsResource(),
+				Title:  title,
+			}
+			item = edge
+		} else {
+			node := newNode(e.time, e.worker)
+			node.Data = nodeData{ID: e.from, Label: e.label}
+			item = node
+		}
+		itemJson, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "\n%s,", itemJson); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func (v Vis) RenderToFile(filename string) error {
-	filename = filename + ".html"
+func (v *Vis) Extension() string {
+	return ".html"
+}
 
+func (v *Vis) Render(w io.Writer) error {
+	script := cdnScript
+	if v.options.Offline {
+		var err error
+		if script, err = assets.InlineScript(assets.VisNetwork); err != nil {
+			return err
+		}
+	}
+
+	// Events are only ever appended, so this shares the backing array safely.
 	v.mu.Lock()
-	defer v.mu.Unlock()
+	events := v.events[:len(v.events):len(v.events)]
+	v.mu.Unlock()
+
+	head, tail, _ := strings.Cut(fmt.Sprintf(html, script, eventsMarker), eventsMarker)
 
-	file, err := os.Create(filename)
-	if err != nil {
+	if _, err := io.WriteString(w, head); err != nil {
 		return err
 	}
-	defer file.Close()
-
-	_, err = file.WriteString(fmt.Sprintf(html, v.output))
-	if err != nil {
+	if err := writeEvents(w, events); err != nil {
 		return err
 	}
-
-	return nil
+	_, err := io.WriteString(w, tail)
+	return err
 }
@@ -0,0 +1,221 @@
+package vis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/psidex/nomad/internal/graphs"
+	. "github.com/psidex/nomad/internal/lib"
+)
+
+// renderedEvents renders v and decodes the events in the output.
+func renderedEvents(t *testing.T, v *Vis) []map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := v.Render(&buf); err != nil {
+		t.Fatal(err)
+	}
+	_, items, ok := strings.Cut(buf.String(), "let nodesAndEdges = [")
+	if !ok {
+		t.Fatal("the events weren't found in the output")
+	}
+	items, _, _ = strings.Cut(items, "\n];")
+
+	var events []map[string]any
+	if err := json.Unmarshal([]byte("["+strings.TrimSuffix(items, ",")+"]"), &events); err != nil {
+		t.Fatalf("could not decode events %q: %v", items, err)
+	}
+	return events
+}
+
+func TestVisWorker(t *testing.T) {
+	v := NewVis(Options{})
+	v.NotifyStartCrawl(3, "a.example")
+	v.AddHostnameConnection(graphs.Edge{From: "a.example", To: "b.example", Kind: graphs.LinkAnchor})
+	v.NotifyEndCrawl(3, "a.example", false)
+	// Found after a.example's crawl ended, so no worker is known.
+	v.AddHostnameConnection(graphs.Edge{From: "a.example", To: "c.example", Kind: graphs.LinkAnchor})
+
+	expected := []any{3.0, 3.0, 3.0, nil, nil}
+	events := renderedEvents(t, v)
+	if len(events) != len(expected) {
+		t.Fatalf("got %d events, expected %d", len(events), len(expected))
+	}
+	for i, event := range events {
+		if event["worker"] != expected[i] {
+			t.Errorf("event %d has worker %v, expected %v", i, event["worker"], expected[i])
+		}
+	}
+}
+
+// benchmarkConnections returns size unique connections, each host links to 10 others.
+func benchmarkConnections(size int) []graphs.Edge {
+	edges := make([]graphs.Edge, size)
+	for i := range edges {
+		edges[i] = graphs.Edge{
+			From:       fmt.Sprintf("%d.example.com", i/10),
+			To:         fmt.Sprintf("%d.example.org", i),
+			Kind:       graphs.LinkAnchor,
+			AnchorText: "a link",
+		}
+	}
+	return edges
+}
+
+// BenchmarkVis adds and renders graphs of up to 100k edges.
+func BenchmarkVis(b *testing.B) {
+	for _, size := range []int{1000, 10000, 100000} {
+		edges := benchmarkConnections(size)
+
+		b.Run(fmt.Sprintf("add/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				v := NewVis(Options{})
+				v.NotifyStartCrawl(1, edges[0].From)
+				for _, edge := range edges {
+					v.AddHostnameConnection(edge)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("render/%d", size), func(b *testing.B) {
+			v := NewVis(Options{})
+			for _, edge := range edges {
+				v.AddHostnameConnection(edge)
+			}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := v.Render(io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// baselineVis is how Vis used to work, marshalling each event when it's added and
+// appending it to a string, to compare BenchmarkVis against.
+type baselineVis struct {
+	hasher    *StrHasher
+	seenNodes Set
+	seenEdges Set
+	output    string
+}
+
+func (v *baselineVis) appendJson(item any) {
+	itemJson, err := json.Marshal(item)
+	if err != nil {
+		return
+	}
+	v.output += fmt.Sprintf("\n%s,", itemJson)
+}
+
+func (v *baselineVis) AddHostnameConnection(connection graphs.Edge) {
+	now := time.Now().UnixMilli()
+	for _, hostname := range []string{connection.From, connection.To} {
+		key := strconv.Itoa(v.hasher.Hash(hostname))
+		if !v.seenNodes.Contains(key) {
+			v.seenNodes.Add(key)
+			n := newNode(now, nil)
+			n.Data = nodeData{ID: v.hasher.Hash(hostname), Label: hostname}
+			v.appendJson(n)
+		}
+	}
+	fromId, toId := v.hasher.Hash(connection.From), v.hasher.Hash(connection.To)
+	key := strconv.Itoa(fromId) + "\t" + strconv.Itoa(toId)
+	if !v.seenEdges.Contains(key) {
+		v.seenEdges.Add(key)
+		e := newEdge(now, nil)
+		e.Data = edgeData{From: fromId, To: toId}
+		v.appendJson(e)
+	}
+}
+
+// BenchmarkVisBaseline is BenchmarkVis for baselineVis. Adding is quadratic, so it stops
+// at 10k edges, 100k takes minutes.
+func BenchmarkVisBaseline(b *testing.B) {
+	for _, size := range []int{1000, 10000} {
+		edges := benchmarkConnections(size)
+		newBaseline := func() *baselineVis {
+			return &baselineVis{hasher: NewStrHasher(), seenNodes: NewSet(), seenEdges: NewSet()}
+		}
+
+		b.Run(fmt.Sprintf("add/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				v := newBaseline()
+				for _, edge := range edges {
+					v.AddHostnameConnection(edge)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("render/%d", size), func(b *testing.B) {
+			v := newBaseline()
+			for _, edge := range edges {
+				v.AddHostnameConnection(edge)
+			}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := io.WriteString(io.Discard, fmt.Sprintf(html, cdnScript, v.output)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestVisEvents(t *testing.T) {
+	v := NewVis(Options{})
+	v.AddHostnameConnection(graphs.Edge{From: "a.example", To: "b.example", Kind: graphs.LinkAnchor, AnchorText: "B"})
+	// The same pair in either direction is one edge, styled by the first link.
+	v.AddHostnameConnection(graphs.Edge{From: "b.example", To: "a.example", Kind: graphs.LinkScript})
+	v.AddHostnameConnection(graphs.Edge{From: "a.example", To: "b.example", Kind: graphs.LinkImage})
+	v.AddHostnameConnection(graphs.Edge{From: "b.example", To: "c.example", Kind: graphs.LinkScript})
+
+	events := renderedEvents(t, v)
+	var types []string
+	ids := map[string]float64{}
+	for _, event := range events {
+		types = append(types, event["type"].(string))
+		if event["type"] == "node" {
+			data := event["data"].(map[string]any)
+			ids[data["label"].(string)] = data["id"].(float64)
+		}
+	}
+	if expected := []string{"node", "node", "edge", "node", "edge"}; !reflect.DeepEqual(types, expected) {
+		t.Fatalf("got events %v, expected %v", types, expected)
+	}
+
+	expected := []map[string]any{
+		{"from": ids["a.example"], "to": ids["b.example"], "color": graphs.LinkAnchor.Color(), "dashes": false, "title": "a: B"},
+		{"from": ids["b.example"], "to": ids["c.example"], "color": graphs.LinkScript.Color(), "dashes": true, "title": "script"},
+	}
+	for i, event := range []map[string]any{events[2], events[4]} {
+		if data := event["data"]; !reflect.DeepEqual(data, expected[i]) {
+			t.Errorf("edge %d: got %v, expected %v", i, data, expected[i])
+		}
+	}
+
+	var last float64
+	for i, event := range events {
+		time, ok := event["time"].(float64)
+		if !ok || time < last {
+			t.Errorf("event %d has time %v, expected it in order", i, event["time"])
+		}
+		last = time
+	}
+}
+
+func TestVisRenderEmpty(t *testing.T) {
+	if events := renderedEvents(t, NewVis(Options{})); len(events) != 0 {
+		t.Errorf("got %d events, expected none", len(events))
+	}
+}
@@ -0,0 +1,82 @@
+package graphs
+
+import "math"
+
+// WeightedEdge is every link connecting two hostnames, see EdgeWeights.
+type WeightedEdge struct {
+	From, To string   // The direction the first link was found in.
+	Kind     LinkKind // The kind of the first link found.
+	Weight   int      // How many links there are, in either direction if collapsed.
+	// Reverse is how many of the links are from To to From, it's only ever set if
+	// direction is collapsed.
+	Reverse int
+}
+
+// Bidirectional returns true if there are links in both directions.
+func (w WeightedEdge) Bidirectional() bool {
+	return w.Reverse > 0 && w.Reverse < w.Weight
+}
+
+// EdgeWeights counts the links connecting each pair of hostnames so visual providers can
+// draw one weighted edge for each, regardless of kind. If direction is collapsed links
+// from A to B and from B to A are counted in the same edge, otherwise each direction is
+// its own edge. Self connections are ignored. EdgeWeights is not thread-safe.
+type EdgeWeights struct {
+	collapseDirection bool
+	index             map[[2]string]int
+	edges             []WeightedEdge
+}
+
+func NewEdgeWeights(collapseDirection bool) *EdgeWeights {
+	return &EdgeWeights{
+		collapseDirection: collapseDirection,
+		index:             make(map[[2]string]int),
+	}
+}
+
+// CollapseDirection returns true if links in either direction are counted in one edge.
+func (w *EdgeWeights) CollapseDirection() bool {
+	return w.collapseDirection
+}
+
+// Add counts the edge's links and returns the index of the edge they were counted in,
+// and true if it's a new edge. The index is -1 for self connections.
+func (w *EdgeWeights) Add(edge Edge) (int, bool) {
+	if edge.From == edge.To {
+		return -1, false
+	}
+	links := max(edge.Count, 1)
+
+	if i, ok := w.index[[2]string{edge.From, edge.To}]; ok {
+		w.edges[i].Weight += links
+		return i, false
+	}
+	if w.collapseDirection {
+		if i, ok := w.index[[2]string{edge.To, edge.From}]; ok {
+			w.edges[i].Weight += links
+			w.edges[i].Reverse += links
+			return i, false
+		}
+	}
+
+	i := len(w.edges)
+	w.index[[2]string{edge.From, edge.To}] = i
+	w.edges = append(w.edges, WeightedEdge{From: edge.From, To: edge.To, Kind: edge.Kind, Weight: links})
+	return i, true
+}
+
+// Get returns the edge at index i.
+func (w *EdgeWeights) Get(i int) WeightedEdge {
+	return w.edges[i]
+}
+
+// Edges returns a copy of every edge in the order they were found.
+func (w *EdgeWeights) Edges() []WeightedEdge {
+	return append([]WeightedEdge(nil), w.edges...)
+}
+
+// EdgeSize returns how thick to draw an edge with the given weight, it grows
+// logarithmically so heavily linked hostnames don't hide everything else.
+func EdgeSize(weight int) float64 {
+	return min(2+math.Log2(float64(max(weight, 1))), 8)
+}
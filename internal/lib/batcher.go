@@ -0,0 +1,81 @@
+package lib
+
+import (
+	"sync"
+	"time"
+)
+
+// Batcher queues items and passes them to a write function in batches of up to size,
+// at least every interval, from a single goroutine. Add only blocks if queueSize items
+// are already waiting.
+type Batcher[T any] struct {
+	items     chan T
+	done      chan struct{}
+	write     func([]T) error
+	size      int
+	interval  time.Duration
+	err       error
+	closeOnce *sync.Once
+}
+
+func NewBatcher[T any](size int, interval time.Duration, queueSize int, write func([]T) error) *Batcher[T] {
+	b := &Batcher[T]{
+		items:     make(chan T, queueSize),
+		done:      make(chan struct{}),
+		write:     write,
+		size:      size,
+		interval:  interval,
+		closeOnce: &sync.Once{},
+	}
+	go b.run()
+	return b
+}
+
+// Add queues item to be written, it must not be called after Close.
+func (b *Batcher[T]) Add(item T) {
+	b.items <- item
+}
+
+// Close writes any queued items and stops the batcher, returning the last error from
+// write if there was one.
+func (b *Batcher[T]) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.items)
+	})
+	<-b.done
+	return b.err
+}
+
+func (b *Batcher[T]) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	batch := make([]T, 0, b.size)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := b.write(batch); err != nil {
+			b.err = err
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case item, ok := <-b.items:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) >= b.size {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/gob"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// BloomSet is a probabilistic set backed by a Bloom filter. Contains may return true
+// for an element that was never added (at roughly the configured false positive rate),
+// but never returns false for one that was. It uses a fixed amount of memory no matter
+// how many elements are added. BloomSet is thread-safe and should be held as a pointer.
+type BloomSet struct {
+	mu   *sync.RWMutex
+	bits []uint64
+	m    uint64 // Number of bits.
+	k    uint64 // Number of hash functions.
+	n    uint64 // Number of elements added.
+	// removed holds elements that have been removed since they were added, Bloom
+	// filters can't forget elements so this should be kept small.
+	removed map[string]struct{}
+}
+
+// NewBloomSet creates a BloomSet sized to hold capacity elements with the given false
+// positive rate (e.g. 0.01 for 1%).
+func NewBloomSet(capacity uint, falsePositiveRate float64) *BloomSet {
+	n := math.Max(float64(capacity), 1)
+	p := math.Min(math.Max(falsePositiveRate, 1e-9), 0.5)
+
+	// See https://en.wikipedia.org/wiki/Bloom_filter#Optimal_number_of_hash_functions.
+	m := uint64(math.Ceil(-n * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(math.Round(float64(m)/n*math.Ln2), 1))
+
+	return &BloomSet{
+		mu:      &sync.RWMutex{},
+		bits:    make([]uint64, (m+63)/64),
+		m:       m,
+		k:       k,
+		removed: make(map[string]struct{}),
+	}
+}
+
+// locations returns the k bit positions for elem using double hashing.
+func (b *BloomSet) locations(elem string) []uint64 {
+	h1 := fnv.New64a()
+	h1.Write([]byte(elem))
+	h2 := fnv.New64()
+	h2.Write([]byte(elem))
+	a, c := h1.Sum64(), h2.Sum64()|1
+
+	locs := make([]uint64, b.k)
+	for i := uint64(0); i < b.k; i++ {
+		locs[i] = (a + i*c) % b.m
+	}
+	return locs
+}
+
+func (b *BloomSet) contains(elem string) bool {
+	if _, ok := b.removed[elem]; ok {
+		return false
+	}
+	for _, loc := range b.locations(elem) {
+		if b.bits[loc/64]&(1<<(loc%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *BloomSet) Add(elem string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.contains(elem) {
+		return
+	}
+	if _, ok := b.removed[elem]; ok {
+		delete(b.removed, elem)
+		return
+	}
+	for _, loc := range b.locations(elem) {
+		b.bits[loc/64] |= 1 << (loc % 64)
+	}
+	b.n++
+}
+
+// Remove makes Contains return false for elem until it's added again. elem must have
+// been added, as a false positive can't be told apart from it and would still be taken
+// off Size.
+func (b *BloomSet) Remove(elem string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.contains(elem) {
+		b.removed[elem] = struct{}{}
+	}
+}
+
+func (b *BloomSet) Contains(elem string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.contains(elem)
+}
+
+// Size returns the number of elements added, which may be an underestimate as adding
+// an element that is a false positive isn't counted.
+func (b *BloomSet) Size() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return int(b.n) - len(b.removed)
+}
+
+// FalsePositiveRate estimates the current chance that Contains returns true for an
+// element that was never added.
+func (b *BloomSet) FalsePositiveRate() float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	k, m, n := float64(b.k), float64(b.m), float64(b.n)
+	return math.Pow(1-math.Exp(-k*n/m), k)
+}
+
+// Clone returns a copy of b that doesn't change when b does, which is much quicker than
+// serializing it.
+func (b *BloomSet) Clone() *BloomSet {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	clone := &BloomSet{
+		mu:      &sync.RWMutex{},
+		bits:    make([]uint64, len(b.bits)),
+		m:       b.m,
+		k:       b.k,
+		n:       b.n,
+		removed: make(map[string]struct{}, len(b.removed)),
+	}
+	copy(clone.bits, b.bits)
+	for elem := range b.removed {
+		clone.removed[elem] = struct{}{}
+	}
+	return clone
+}
+
+// bloomSetData is the serialized form of a BloomSet.
+type bloomSetData struct {
+	Bits    []uint64
+	M, K, N uint64
+	Removed []string
+}
+
+func (b *BloomSet) MarshalBinary() ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	data := bloomSetData{Bits: b.bits, M: b.m, K: b.k, N: b.n}
+	for elem := range b.removed {
+		data.Removed = append(data.Removed, elem)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the contents of b, including its size, with data from
+// MarshalBinary.
+func (b *BloomSet) UnmarshalBinary(raw []byte) error {
+	var data bloomSetData
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&data); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bits, b.m, b.k, b.n = data.Bits, data.M, data.K, data.N
+	b.removed = make(map[string]struct{}, len(data.Removed))
+	for _, elem := range data.Removed {
+		b.removed[elem] = struct{}{}
+	}
+	return nil
+}
@@ -40,3 +40,13 @@ func (duration *Duration) UnmarshalJSON(b []byte) error {
 
 	return nil
 }
+
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(duration.String())
+}
+
+// Set and String allow a Duration to be used as a flag.Value.
+func (duration *Duration) Set(value string) (err error) {
+	duration.Duration, err = time.ParseDuration(value)
+	return err
+}
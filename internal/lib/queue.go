@@ -6,58 +6,67 @@ import (
 )
 
 // Queue is thread-safe and should be held as a pointer.
-type Queue struct {
-	items []string
+type Queue[T any] struct {
+	items []T
 	mu    *sync.RWMutex
 }
 
-func NewQueue() *Queue {
-	return &Queue{
-		items: []string{},
+func NewQueue[T any]() *Queue[T] {
+	return &Queue[T]{
+		items: []T{},
 		mu:    &sync.RWMutex{},
 	}
 }
 
-func (q *Queue) Enqueue(item string) {
+func (q *Queue[T]) Enqueue(item T) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 	q.items = append(q.items, item)
 }
 
-// Dequeue pops from the front of the queue (FIFO).
-func (q *Queue) Dequeue() string {
+// Dequeue pops from the front of the queue (FIFO), ok is false if the queue is empty.
+func (q *Queue[T]) Dequeue() (item T, ok bool) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
 	if len(q.items) == 0 {
-		return ""
+		return item, false
 	}
 
-	item := q.items[0]
+	item = q.items[0]
 	q.items = q.items[1:]
-	return item
+	return item, true
 }
 
-// RandomDequeue pops a random item from the queue.
-func (q *Queue) RandomDequeue() string {
+// RandomDequeue pops a random item from the queue, ok is false if the queue is empty.
+func (q *Queue[T]) RandomDequeue() (item T, ok bool) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
 	if len(q.items) == 0 {
-		return ""
+		return item, false
 	}
 
 	i := rand.Intn(len(q.items))
-	item := q.items[i]
+	item = q.items[i]
 
 	q.items = append(q.items[:i], q.items[i+1:]...)
 
-	return item
+	return item, true
 
 }
 
-func (q Queue) Size() int {
+func (q *Queue[T]) Size() int {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
 	return len(q.items)
 }
+
+// Items returns a copy of the items in the queue, from front to back.
+func (q *Queue[T]) Items() []T {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	items := make([]T, len(q.items))
+	copy(items, q.items)
+	return items
+}
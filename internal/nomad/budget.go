@@ -0,0 +1,122 @@
+package nomad
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/psidex/nomad/internal/lib"
+)
+
+// budget keeps track of the crawl budgets from Config. It is thread-safe and should be
+// held as a pointer.
+type budget struct {
+	maxHostsFetched uint
+	maxHostnames    uint
+	maxDepth        uint
+
+	fetched *atomic.Uint64
+	// hostnamesMu makes checking the size of seen and adding to it atomic. seen is only
+	// used if maxHostnames is set, so an uncapped crawl doesn't hold every hostname.
+	hostnamesMu *sync.Mutex
+	seen        lib.Set
+	// depthLimited is set once a host has been left uncrawled because of maxDepth.
+	depthLimited *atomic.Bool
+}
+
+func newBudget(cfg Config) *budget {
+	b := &budget{
+		maxHostsFetched: cfg.MaxHostsFetched,
+		maxHostnames:    cfg.MaxHostnames,
+		maxDepth:        cfg.MaxDepth,
+		fetched:         &atomic.Uint64{},
+		hostnamesMu:     &sync.Mutex{},
+		depthLimited:    &atomic.Bool{},
+	}
+	if b.maxHostnames != 0 {
+		b.seen = lib.NewSet()
+	}
+	return b
+}
+
+// reserveFetch is called before fetching a host. allowed is false if the budget has
+// already been used up, and last is true if this is the final fetch allowed.
+func (b *budget) reserveFetch() (allowed, last bool) {
+	fetched := b.fetched.Add(1)
+	if b.maxHostsFetched == 0 {
+		return true, false
+	}
+	if fetched > uint64(b.maxHostsFetched) {
+		// Give the reservation back so the count stays accurate.
+		b.fetched.Add(^uint64(0))
+		return false, false
+	}
+	return true, fetched == uint64(b.maxHostsFetched)
+}
+
+// refundFetch gives back a reservation from reserveFetch, for a host that will be
+// retried.
+func (b *budget) refundFetch() {
+	b.fetched.Add(^uint64(0))
+}
+
+// discoverHostname records that hostname has been found, returning false if it is new
+// and there is no budget left for it.
+func (b *budget) discoverHostname(hostname string) bool {
+	if b.maxHostnames == 0 {
+		return true
+	}
+	b.hostnamesMu.Lock()
+	defer b.hostnamesMu.Unlock()
+	if b.seen.Contains(hostname) {
+		return true
+	}
+	if uint(b.seen.Size()) >= b.maxHostnames {
+		return false
+	}
+	b.seen.Add(hostname)
+	return true
+}
+
+// hostnames returns how many distinct hostnames have been discovered, or 0 if they
+// aren't being tracked.
+func (b *budget) hostnames() int {
+	if b.maxHostnames == 0 {
+		return 0
+	}
+	b.hostnamesMu.Lock()
+	defer b.hostnamesMu.Unlock()
+	return b.seen.Size()
+}
+
+// exceedsDepth returns true if a host at depth shouldn't be crawled.
+func (b *budget) exceedsDepth(depth uint) bool {
+	if b.maxDepth == 0 || depth <= b.maxDepth {
+		return false
+	}
+	b.depthLimited.Store(true)
+	return true
+}
+
+// snapshot returns the discovered hostnames and the number of hosts fetched.
+func (b *budget) snapshot() (hostnames []string, fetched uint64) {
+	if b.maxHostnames == 0 {
+		return nil, b.fetched.Load()
+	}
+	b.hostnamesMu.Lock()
+	defer b.hostnamesMu.Unlock()
+	return b.seen.AsSlice(), b.fetched.Load()
+}
+
+// restore sets the budget state from a snapshot. The hostnames are ignored if they
+// aren't being tracked.
+func (b *budget) restore(hostnames []string, fetched uint64) {
+	b.fetched.Store(fetched)
+	if b.maxHostnames == 0 {
+		return
+	}
+	b.hostnamesMu.Lock()
+	defer b.hostnamesMu.Unlock()
+	for _, hostname := range hostnames {
+		b.seen.Add(hostname)
+	}
+}
@@ -0,0 +1,90 @@
+package nomad
+
+import "testing"
+
+func TestReserveFetch(t *testing.T) {
+	b := newBudget(Config{MaxHostsFetched: 2})
+
+	if allowed, last := b.reserveFetch(); !allowed || last {
+		t.Fatalf("first fetch: got %t %t, expected allowed and not last", allowed, last)
+	}
+	// A retried host gives its reservation back.
+	b.refundFetch()
+	if allowed, last := b.reserveFetch(); !allowed || last {
+		t.Fatalf("retried fetch: got %t %t, expected allowed and not last", allowed, last)
+	}
+	if allowed, last := b.reserveFetch(); !allowed || !last {
+		t.Fatalf("second fetch: got %t %t, expected allowed and last", allowed, last)
+	}
+	if allowed, _ := b.reserveFetch(); allowed {
+		t.Fatal("third fetch: expected it not to be allowed")
+	}
+	if _, fetched := b.snapshot(); fetched != 2 {
+		t.Errorf("got %d fetched, expected 2", fetched)
+	}
+}
+
+func TestReserveFetchUnlimited(t *testing.T) {
+	b := newBudget(Config{})
+	for i := 0; i < 100; i++ {
+		if allowed, last := b.reserveFetch(); !allowed || last {
+			t.Fatalf("fetch %d: got %t %t, expected allowed and not last", i, allowed, last)
+		}
+	}
+}
+
+func TestDiscoverHostname(t *testing.T) {
+	b := newBudget(Config{MaxHostnames: 2})
+
+	for _, test := range []struct {
+		hostname string
+		expected bool
+	}{
+		{"a.example", true},
+		{"b.example", true},
+		{"a.example", true}, // Already seen, so it doesn't need any budget.
+		{"c.example", false},
+	} {
+		if got := b.discoverHostname(test.hostname); got != test.expected {
+			t.Errorf("%s: got %t, expected %t", test.hostname, got, test.expected)
+		}
+	}
+	if got := b.hostnames(); got != 2 {
+		t.Errorf("got %d hostnames, expected 2", got)
+	}
+}
+
+func TestDiscoverHostnameUnlimited(t *testing.T) {
+	b := newBudget(Config{})
+	for _, hostname := range []string{"a.example", "b.example", "c.example"} {
+		if !b.discoverHostname(hostname) {
+			t.Errorf("%s: expected it to be allowed", hostname)
+		}
+	}
+	// Uncapped crawls don't track hostnames, so they aren't checkpointed either.
+	if hostnames, _ := b.snapshot(); len(hostnames) != 0 || b.hostnames() != 0 {
+		t.Errorf("got hostnames %v, expected none to be tracked", hostnames)
+	}
+}
+
+func TestBudgetRestore(t *testing.T) {
+	b := newBudget(Config{MaxHostnames: 2, MaxHostsFetched: 5})
+	b.restore([]string{"a.example", "b.example"}, 4)
+
+	if b.discoverHostname("c.example") {
+		t.Error("expected the restored hostnames to use the budget")
+	}
+	if allowed, last := b.reserveFetch(); !allowed || !last {
+		t.Errorf("got %t %t, expected the restored fetches to leave one", allowed, last)
+	}
+}
+
+func TestExceedsDepth(t *testing.T) {
+	b := newBudget(Config{MaxDepth: 1})
+	if b.exceedsDepth(1) || b.depthLimited.Load() {
+		t.Error("depth 1 shouldn't exceed a max depth of 1")
+	}
+	if !b.exceedsDepth(2) || !b.depthLimited.Load() {
+		t.Error("depth 2 should exceed a max depth of 1 and be recorded")
+	}
+}
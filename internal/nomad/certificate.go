@@ -0,0 +1,59 @@
+package nomad
+
+import (
+	"crypto/tls"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// certificateUrls returns a URL for every hostname in the Subject Alternative Names of
+// the certificate a response was served with, or nil if it wasn't served over TLS.
+// Wildcard names are replaced by the domain they cover, e.g. *.example.com ->
+// example.com, unless that's a public suffix such as co.uk.
+func certificateUrls(state *tls.ConnectionState) []foundUrl {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var urls []foundUrl
+	for _, name := range state.PeerCertificates[0].DNSNames {
+		name = strings.TrimSuffix(strings.ToLower(name), ".")
+		name = strings.TrimPrefix(name, "*.")
+		if !validHostname(name) || seen[name] {
+			continue
+		}
+		if suffix, icann := publicsuffix.PublicSuffix(name); icann && suffix == name {
+			continue
+		}
+		seen[name] = true
+
+		certUrl := &url.URL{Scheme: "https", Host: name}
+		urls = append(urls, foundUrl{url: certUrl.String(), kind: graphs.LinkTLSSan})
+	}
+	return urls
+}
+
+// validHostname returns true if name is made of non-empty labels of letters, digits,
+// hyphens, and underscores. Certificates hold internationalised names in their ASCII
+// form, so nothing else is valid.
+func validHostname(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
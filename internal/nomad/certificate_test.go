@@ -0,0 +1,185 @@
+package nomad
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/psidex/nomad/internal/frontier"
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+func foundHostnames(urls []foundUrl) []string {
+	var hostnames []string
+	for _, found := range urls {
+		if found.kind != graphs.LinkTLSSan {
+			continue
+		}
+		hostname, err := getHostname(found.url)
+		if err != nil {
+			panic(err)
+		}
+		hostnames = append(hostnames, hostname)
+	}
+	return hostnames
+}
+
+func TestCertificateUrls(t *testing.T) {
+	tests := []struct {
+		name     string
+		dnsNames []string
+		expected []string
+	}{
+		{
+			name:     "plain names",
+			dnsNames: []string{"example.com", "www.example.com"},
+			expected: []string{"example.com", "www.example.com"},
+		},
+		{
+			name:     "wildcards cover their domain",
+			dnsNames: []string{"*.example.com", "*.cdn.example.net"},
+			expected: []string{"example.com", "cdn.example.net"},
+		},
+		{
+			name:     "public suffixes are skipped",
+			dnsNames: []string{"*.co.uk", "com", "*.com", "example.co.uk"},
+			expected: []string{"example.co.uk"},
+		},
+		{
+			name:     "duplicates are removed",
+			dnsNames: []string{"Example.com", "example.com.", "*.example.com", "example.com"},
+			expected: []string{"example.com"},
+		},
+		{
+			name:     "invalid names are skipped",
+			dnsNames: []string{"a*b.example.com", "*.*.example.com", "", "*.", "bad name.com", "ok.example.com"},
+			expected: []string{"ok.example.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{DNSNames: test.dnsNames}}}
+			found := certificateUrls(state)
+			for _, f := range found {
+				if f.url != "https://"+mustHostname(t, f.url) {
+					t.Errorf("%s isn't a hostname URL", f.url)
+				}
+			}
+			if hostnames := foundHostnames(found); fmt.Sprint(hostnames) != fmt.Sprint(test.expected) {
+				t.Errorf("got %v, expected %v", hostnames, test.expected)
+			}
+		})
+	}
+}
+
+func mustHostname(t *testing.T, urlStr string) string {
+	t.Helper()
+	hostname, err := getHostname(urlStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hostname
+}
+
+func TestCertificateUrlsNoTLS(t *testing.T) {
+	if urls := certificateUrls(nil); urls != nil {
+		t.Errorf("got %v without TLS", urls)
+	}
+	if urls := certificateUrls(&tls.ConnectionState{}); urls != nil {
+		t.Errorf("got %v without certificates", urls)
+	}
+}
+
+// newCertificate generates a self signed certificate for 127.0.0.1 with dnsNames.
+func newCertificate(t *testing.T, dnsNames ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nomad test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+		DNSNames:              dnsNames,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// newTestNomad returns a Nomad that's ready for getUrls to be called, using client.
+func newTestNomad(cfg Config, client *http.Client) *Nomad {
+	n := NewNomad(cfg, client, graphs.NewRecorder())
+	n.scheduler = frontier.NewScheduler(0, 0)
+	n.sources = cfg.linkSources()
+	return n
+}
+
+func TestGetUrlsCertificateNames(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="https://linked.example.org/">linked</a>`)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{newCertificate(t, "*.nomad.test", "nomad.test", "Other.Example.", "*.co.uk")},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	for _, test := range []struct {
+		sources  []graphs.LinkKind
+		expected []string
+	}{
+		{sources: []graphs.LinkKind{graphs.LinkAnchor}, expected: nil},
+		{sources: []graphs.LinkKind{graphs.LinkAnchor, graphs.LinkTLSSan}, expected: []string{"nomad.test", "other.example"}},
+	} {
+		n := newTestNomad(Config{LinkSources: test.sources}, server.Client())
+		page, err := n.getUrls(context.Background(), server.URL, http.Header{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.urls) != len(test.expected)+1 {
+			t.Errorf("with %v got %d URLs, expected the link and %d certificate names", test.sources, len(page.urls), len(test.expected))
+		}
+		if hostnames := foundHostnames(page.urls); fmt.Sprint(hostnames) != fmt.Sprint(test.expected) {
+			t.Errorf("with %v got certificate names %v, expected %v", test.sources, hostnames, test.expected)
+		}
+	}
+}
+
+func TestGetUrlsCertificateNamesPlainHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="https://linked.example.org/">linked</a>`)
+	}))
+	defer server.Close()
+
+	n := newTestNomad(Config{LinkSources: []graphs.LinkKind{graphs.LinkTLSSan}}, server.Client())
+	page, err := n.getUrls(context.Background(), server.URL, http.Header{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostnames := foundHostnames(page.urls); hostnames != nil {
+		t.Errorf("got certificate names %v over plain HTTP", hostnames)
+	}
+}
@@ -0,0 +1,89 @@
+package nomad
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"time"
+
+	"github.com/psidex/nomad/internal/checkpoint"
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// addConnection records a hostname connection in the graph, and in the edge log if
+// checkpointing is enabled.
+func (n *Nomad) addConnection(edge graphs.Edge) {
+	n.graph.AddHostnameConnection(edge)
+	if n.edges != nil {
+		n.edges.Add(edge.From, edge.To, edge.Kind, max(edge.Count, 1))
+	}
+}
+
+// complete records the connections found at url and marks it as done. Checkpoints wait
+// for both, so one is never saved with url's connections while url is still queued,
+// which would count them again when url is crawled after resuming.
+func (n *Nomad) complete(url string, connections []graphs.Edge) {
+	for _, edge := range connections {
+		n.graph.AddHostnameConnection(edge)
+	}
+
+	n.checkpointMu.RLock()
+	defer n.checkpointMu.RUnlock()
+	if n.edges != nil {
+		for _, edge := range connections {
+			n.edges.Add(edge.From, edge.To, edge.Kind, max(edge.Count, 1))
+		}
+	}
+	n.frontier.Done(url)
+}
+
+// resume restores the crawl from the checkpoint file if there is one.
+func (n *Nomad) resume() error {
+	cp, err := checkpoint.Load(n.cfg.CheckpointFile, n.frontier)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("No checkpoint found at %s, starting a new crawl\n", n.cfg.CheckpointFile)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	n.budget.restore(cp.Hostnames, cp.HostsFetched)
+	for _, edge := range cp.Edges {
+		kind := edge.Kind
+		if kind == "" {
+			// Checkpoints from before link kinds were recorded only used <a href>.
+			kind = graphs.LinkAnchor
+		}
+		// Only the connection and its links are checkpointed, not where they were found.
+		n.addConnection(graphs.Edge{From: edge.From, To: edge.To, Kind: kind, Count: max(edge.Count, 1)})
+	}
+
+	log.Printf("Resumed from checkpoint created %s, %d queued, %d visited, %d edges\n",
+		cp.Created.Format(time.RFC3339), cp.Queued, cp.Visited, len(cp.Edges))
+
+	return nil
+}
+
+// saveCheckpoint writes the current state of the crawl to the checkpoint file.
+func (n *Nomad) saveCheckpoint() {
+	cp := &checkpoint.Checkpoint{Created: time.Now()}
+	// The frontier and edges have to be snapshotted together, see complete.
+	n.checkpointMu.Lock()
+	snapshot, err := n.frontier.Snapshot()
+	if err != nil {
+		n.checkpointMu.Unlock()
+		log.Printf("Could not snapshot frontier, err: %v\n", err)
+		return
+	}
+	cp.Edges = n.edges.Edges()
+	cp.Hostnames, cp.HostsFetched = n.budget.snapshot()
+	n.checkpointMu.Unlock()
+	defer snapshot.Close()
+
+	if err := checkpoint.Save(n.cfg.CheckpointFile, cp, snapshot); err != nil {
+		log.Printf("Could not save checkpoint, err: %v\n", err)
+		return
+	}
+	log.Printf("Saved checkpoint, %d queued, %d visited, %d edges\n",
+		cp.Queued, cp.Visited, len(cp.Edges))
+}
@@ -0,0 +1,65 @@
+package nomad
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/psidex/nomad/internal/checkpoint"
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// newCheckpointNomad sets up what Run would for a crawl that checkpoints to filename.
+func newCheckpointNomad(t *testing.T, filename string) *Nomad {
+	cfg := Config{CheckpointFile: filename, Resume: true}
+	n := newTestNomad(cfg, &http.Client{})
+	var err error
+	if n.frontier, err = n.newFrontier(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { n.frontier.Close() })
+	n.budget = newBudget(cfg)
+	n.edges = checkpoint.NewEdgeLog()
+	return n
+}
+
+func TestCheckpointInProgressUrl(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "checkpoint.json")
+	n := newCheckpointNomad(t, filename)
+	n.frontier.AddUrl("https://a.example/", 0)
+	n.frontier.AddUrl("https://b.example/", 0)
+
+	current, err := n.frontier.PopUrl(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	connections := []graphs.Edge{{From: "a.example", To: "c.example", Kind: graphs.LinkAnchor, Count: 2}}
+
+	// Until the URL is complete it's checkpointed as queued, without its connections.
+	n.saveCheckpoint()
+	resumed := newCheckpointNomad(t, filename)
+	if err := resumed.resume(); err != nil {
+		t.Fatal(err)
+	}
+	if size := resumed.frontier.Size(); size != 2 {
+		t.Errorf("got %d queued before completing, expected 2", size)
+	}
+	if edges := resumed.edges.Edges(); len(edges) != 0 {
+		t.Errorf("got edges %v before completing, expected none", edges)
+	}
+
+	n.complete(current.Url, connections)
+	n.saveCheckpoint()
+	resumed = newCheckpointNomad(t, filename)
+	if err := resumed.resume(); err != nil {
+		t.Fatal(err)
+	}
+	if size := resumed.frontier.Size(); size != 1 {
+		t.Errorf("got %d queued after completing, expected 1", size)
+	}
+	edges := resumed.edges.Edges()
+	if len(edges) != 1 || edges[0].Count != 2 {
+		t.Errorf("got edges %v after completing, expected one with a count of 2", edges)
+	}
+}
@@ -0,0 +1,179 @@
+package nomad
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/psidex/nomad/internal/graphs"
+	"github.com/psidex/nomad/internal/lib"
+)
+
+// defaultFrontierMemoryLimit is how many queued URLs the disk frontier keeps in memory
+// if Config.FrontierMemoryLimit isn't set.
+const defaultFrontierMemoryLimit = 10_000
+
+// defaultMaxRedirects is how many redirects are followed if Config.MaxRedirects isn't
+// set, the same as net/http's default.
+const defaultMaxRedirects = 10
+
+// The defaults for the bloom visited set if they aren't configured.
+const (
+	defaultBloomCapacity          = 1_000_000
+	defaultBloomFalsePositiveRate = 0.001
+)
+
+// The limits on the bloom visited set's size, at both limits it uses about 540MB.
+const (
+	maxBloomCapacity          = 100_000_000
+	minBloomFalsePositiveRate = 1e-9
+)
+
+type Config struct {
+	// WorkerCooldown is how long each worker sleeps after every URL, regardless of
+	// host. HostDelay and IPDelay are usually a better way to be polite.
+	WorkerCooldown lib.Duration `json:"workerCooldown"`
+	WorkerCount    uint         `json:"workerCount"`
+	InitialUrls    []string     `json:"initialUrls"`
+	RandomCrawl    bool         `json:"randomCrawl"`
+	// DiscoveryTree only records the connection that first discovered each hostname,
+	// producing a spanning tree in discovery order instead of the full link graph.
+	DiscoveryTree bool `json:"discoveryTree"`
+	// RespectRobotsTxt makes Nomad fetch each host's robots.txt before crawling it,
+	// skipping hosts that disallow "/" and waiting for any Crawl-delay.
+	RespectRobotsTxt bool `json:"respectRobotsTxt"`
+	// HostDelay and IPDelay are the minimum time between requests to the same hostname
+	// and to the same resolved IP address. Hosts that respond with 429 or 503 are
+	// backed off further, and retried up to MaxRetries times.
+	HostDelay  lib.Duration `json:"hostDelay"`
+	IPDelay    lib.Duration `json:"ipDelay"`
+	MaxRetries uint         `json:"maxRetries"`
+	// MaxRedirects is how many redirects are followed when fetching a host, 0 uses the
+	// default of 10. Redirects to other hostnames aren't followed, they're recorded as
+	// connections and queued.
+	MaxRedirects uint `json:"maxRedirects"`
+	// Frontier is either "memory" (the default) or "disk". The disk frontier keeps at
+	// most FrontierMemoryLimit queued URLs in memory and stores everything else in a
+	// database at FrontierPath, or a temporary file if that's empty.
+	Frontier            string `json:"frontier"`
+	FrontierPath        string `json:"frontierPath"`
+	FrontierMemoryLimit int    `json:"frontierMemoryLimit"`
+	// VisitedSet is either "exact" (the default) or "bloom". The bloom set, which only
+	// works with the memory frontier, uses a fixed amount of memory sized for
+	// BloomCapacity URLs at BloomFalsePositiveRate, at the cost of occasionally
+	// skipping a host it thinks it has already visited.
+	VisitedSet             string  `json:"visitedSet"`
+	BloomCapacity          uint    `json:"bloomCapacity"`
+	BloomFalsePositiveRate float64 `json:"bloomFalsePositiveRate"`
+	// CheckpointFile is where the frontier and recorded edges are periodically saved,
+	// every CheckpointInterval and when the crawl stops. If Resume is set and the file
+	// exists, the crawl continues from it instead of starting again.
+	CheckpointFile     string       `json:"checkpointFile"`
+	CheckpointInterval lib.Duration `json:"checkpointInterval"`
+	Resume             bool         `json:"resume"`
+	// Budgets that end the crawl once reached, 0 means unlimited. MaxHostsFetched is
+	// how many hosts can be requested, MaxHostnames is how many distinct hostnames can
+	// be discovered, and MaxDepth is the most hops a host can be from InitialUrls to
+	// still be crawled.
+	MaxHostsFetched uint `json:"maxHostsFetched"`
+	MaxHostnames    uint `json:"maxHostnames"`
+	MaxDepth        uint `json:"maxDepth"`
+	// LinkSources are the parts of each page that URLs are taken from, see
+	// graphs.LinkKinds. If it's empty only <a href> is used. The graphs.LinkTLSSan
+	// source only records the hostnames in each certificate, unless
+	// EnqueueCertificateNames is set to crawl them too.
+	LinkSources             []graphs.LinkKind `json:"linkSources"`
+	EnqueueCertificateNames bool              `json:"enqueueCertificateNames"`
+}
+
+// maxRedirects returns MaxRedirects with the default applied.
+func (c Config) maxRedirects() int {
+	if c.MaxRedirects == 0 {
+		return defaultMaxRedirects
+	}
+	return int(c.MaxRedirects)
+}
+
+// linkSources returns LinkSources as a set, with the default applied.
+func (c Config) linkSources() map[graphs.LinkKind]bool {
+	sources := map[graphs.LinkKind]bool{}
+	for _, kind := range c.LinkSources {
+		sources[kind] = true
+	}
+	if len(sources) == 0 {
+		sources[graphs.LinkAnchor] = true
+	}
+	return sources
+}
+
+// Validate checks that the config can be used to run a crawl.
+func (c Config) Validate() error {
+	if c.WorkerCount == 0 {
+		return errors.New("workerCount must be at least 1")
+	}
+
+	if len(c.InitialUrls) == 0 {
+		return errors.New("at least one initial URL is required")
+	}
+	for _, initialUrl := range c.InitialUrls {
+		parsed, err := url.Parse(initialUrl)
+		if err != nil {
+			return fmt.Errorf("invalid initial URL %q: %w", initialUrl, err)
+		}
+		if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("initial URL %q must be an absolute http(s) URL", initialUrl)
+		}
+	}
+
+	for name, d := range map[string]lib.Duration{
+		"workerCooldown":     c.WorkerCooldown,
+		"hostDelay":          c.HostDelay,
+		"ipDelay":            c.IPDelay,
+		"checkpointInterval": c.CheckpointInterval,
+	} {
+		if d.Duration < 0 {
+			return fmt.Errorf("%s can't be negative", name)
+		}
+	}
+
+	switch c.Frontier {
+	case "", "memory", "disk":
+	default:
+		return fmt.Errorf("unknown frontier: %s", c.Frontier)
+	}
+	if c.FrontierMemoryLimit < 0 {
+		return errors.New("frontierMemoryLimit can't be negative")
+	}
+
+	switch c.VisitedSet {
+	case "", "exact":
+	case "bloom":
+		if c.Frontier == "disk" {
+			return errors.New("the disk frontier only supports the exact visited set")
+		}
+		if c.BloomCapacity > maxBloomCapacity {
+			return fmt.Errorf("bloomCapacity can't be more than %d", maxBloomCapacity)
+		}
+		if c.BloomFalsePositiveRate != 0 &&
+			(c.BloomFalsePositiveRate < minBloomFalsePositiveRate || c.BloomFalsePositiveRate >= 1) {
+			return fmt.Errorf("bloomFalsePositiveRate must be between %g and 1", minBloomFalsePositiveRate)
+		}
+	default:
+		return fmt.Errorf("unknown visited set: %s", c.VisitedSet)
+	}
+
+	for _, kind := range c.LinkSources {
+		if _, err := graphs.ParseLinkKind(string(kind)); err != nil {
+			return err
+		}
+	}
+	if c.EnqueueCertificateNames && !c.linkSources()[graphs.LinkTLSSan] {
+		return fmt.Errorf("enqueueCertificateNames requires the %s link source", graphs.LinkTLSSan)
+	}
+
+	if c.Resume && c.CheckpointFile == "" {
+		return errors.New("resume requires a checkpointFile")
+	}
+
+	return nil
+}
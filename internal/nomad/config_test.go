@@ -0,0 +1,27 @@
+package nomad
+
+import "testing"
+
+func TestValidateBloomLimits(t *testing.T) {
+	valid := Config{WorkerCount: 1, InitialUrls: []string{"https://example.com/"}, VisitedSet: "bloom"}
+
+	for _, tc := range []struct {
+		name     string
+		capacity uint
+		rate     float64
+		ok       bool
+	}{
+		{"defaults", 0, 0, true},
+		{"max capacity", maxBloomCapacity, defaultBloomFalsePositiveRate, true},
+		{"capacity too large", maxBloomCapacity + 1, 0, false},
+		{"min rate", 0, minBloomFalsePositiveRate, true},
+		{"rate too small", 0, minBloomFalsePositiveRate / 10, false},
+		{"rate too large", 0, 1, false},
+	} {
+		cfg := valid
+		cfg.BloomCapacity, cfg.BloomFalsePositiveRate = tc.capacity, tc.rate
+		if err := cfg.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: Validate() = %v", tc.name, err)
+		}
+	}
+}
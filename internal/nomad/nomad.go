@@ -1,102 +1,384 @@
 package nomad
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/corpix/uarand"
 	"golang.org/x/net/html"
 
+	"github.com/psidex/nomad/internal/checkpoint"
 	"github.com/psidex/nomad/internal/frontier"
 	"github.com/psidex/nomad/internal/graphs"
 	"github.com/psidex/nomad/internal/lib"
+	"github.com/psidex/nomad/internal/robots"
 )
 
-// TODO: Configurable "stealth mode" - if not in stealth mode, check robots.txt, etc.
+// robotsUserAgent is the name Nomad looks for in robots.txt files, if there is no group
+// for it then the "*" group is used.
+const robotsUserAgent = "nomad"
 
-type Config struct {
-	WorkerCooldown lib.Duration `json:"workerCooldown"`
-	WorkerCount    uint         `json:"workerCount"`
-	InitialUrls    []string     `json:"initialUrls"`
-	RandomCrawl    bool         `json:"randomCrawl"`
-}
+// errSlowDown is returned by getUrls when the host responds with 429 or 503.
+var errSlowDown = errors.New("host asked us to slow down")
+
+// maxCrawlDelay caps how long a robots.txt Crawl-delay can be, so one host can't stall a
+// worker indefinitely.
+const maxCrawlDelay = time.Second * 30
 
 type Nomad struct {
 	// Set in NewNomad(...).
 	cfg    Config
 	client *http.Client
-	graph  graphs.GraphProvider
-	// Set / reset at the start of Run().
-	frontier frontier.Frontier
-	cancel   chan struct{}
-	wg       *sync.WaitGroup
+	// robotsClient follows every redirect, RFC 9309 requires following at least 5.
+	robotsClient *http.Client
+	graph        graphs.GraphProvider
+	// mu protects the lifecycle fields below.
+	mu         *sync.Mutex
+	state      State
+	stop       context.CancelFunc
+	stopReason StopReason
+	done       chan struct{}
+	summary    Summary
+	err        error
+	// Set at the start of Run().
+	frontier    *frontier.Frontier
+	robots      *robots.Cache
+	scheduler   *frontier.Scheduler
+	wg          *sync.WaitGroup
+	urlsCrawled *atomic.Int64
+	budget      *budget
+	sources     map[graphs.LinkKind]bool
+	// edges is nil unless checkpointing is enabled.
+	edges *checkpoint.EdgeLog
+	// checkpointMu is held for writing while a checkpoint is taken, see complete.
+	checkpointMu *sync.RWMutex
 }
 
+// NewNomad creates a Nomad that fetches using a copy of hc, with its CheckRedirect
+// replaced so redirects to other hostnames aren't followed. robots.txt is fetched with a
+// copy that uses the default redirect policy.
 func NewNomad(cfg Config, hc *http.Client, gp graphs.GraphProvider) *Nomad {
-	return &Nomad{
-		cfg:    cfg,
-		client: hc,
-		graph:  gp,
+	n := &Nomad{
+		cfg:          cfg,
+		graph:        gp,
+		mu:           &sync.Mutex{},
+		state:        StateIdle,
+		done:         make(chan struct{}),
+		checkpointMu: &sync.RWMutex{},
 	}
+	client := *hc
+	client.CheckRedirect = n.checkRedirect
+	n.client = &client
+	robotsClient := *hc
+	robotsClient.CheckRedirect = nil
+	n.robotsClient = &robotsClient
+	return n
 }
 
-// Cancel gracefully stops all the workers once they're finished processing their
-// current URL, and blocks until all the goroutines are exited.
-func (n *Nomad) Cancel() {
-	close(n.cancel)
-	n.wg.Wait()
+// State returns the current lifecycle state.
+func (n *Nomad) State() State {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.state
 }
 
-func (n *Nomad) Run() error {
-	n.frontier = frontier.NewFrontier(n.cfg.RandomCrawl)
-	n.cancel = make(chan struct{})
+// Run starts the workers and returns once they're running. The crawl stops when ctx is
+// done or Cancel is called, use Wait to block until it has.
+func (n *Nomad) Run(ctx context.Context) (err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.state != StateIdle {
+		return ErrAlreadyRun
+	}
+
+	if err := n.cfg.Validate(); err != nil {
+		return err
+	}
+
+	if n.frontier, err = n.newFrontier(); err != nil {
+		return err
+	}
+	defer func() {
+		// Don't leave the frontier's storage behind if we couldn't start.
+		if err != nil {
+			n.frontier.Close()
+		}
+	}()
+	n.robots = robots.NewCache(n.robotsClient, robotsUserAgent)
+	n.scheduler = frontier.NewScheduler(n.cfg.HostDelay.Duration, n.cfg.IPDelay.Duration)
+	n.frontier.SetScheduler(n.scheduler)
 	n.wg = &sync.WaitGroup{}
+	n.urlsCrawled = &atomic.Int64{}
+	n.budget = newBudget(n.cfg)
+	n.sources = n.cfg.linkSources()
+
+	if n.cfg.CheckpointFile != "" {
+		n.edges = checkpoint.NewEdgeLog()
+		if n.cfg.Resume {
+			if err := n.resume(); err != nil {
+				return err
+			}
+		}
+	}
 
 	for _, initialUrl := range n.cfg.InitialUrls {
 		toAdd, err := getHostnameAsUrl(initialUrl)
 		if err != nil {
 			return err
 		}
-		n.frontier.AddUrl(toAdd)
+		hostname, err := getHostname(toAdd)
+		if err != nil {
+			return err
+		}
+		n.budget.discoverHostname(hostname)
+		n.frontier.AddUrl(toAdd, 0)
 	}
 
+	ctx, n.stop = context.WithCancel(ctx)
+	n.state = StateRunning
+	n.summary.Started = time.Now()
+
 	for i := uint(1); i <= n.cfg.WorkerCount; i++ {
 		n.wg.Add(1)
-		go n.worker(i)
+		go n.worker(ctx, i)
 	}
 
+	go n.supervise(ctx)
+
 	return nil
 }
 
-func (n Nomad) worker(id uint) {
-	defer n.wg.Done()
+// newFrontier creates the frontier chosen in the config.
+func (n *Nomad) newFrontier() (*frontier.Frontier, error) {
+	var visited frontier.VisitedSet
+	switch n.cfg.VisitedSet {
+	case "", "exact":
+		visited = lib.NewSet()
+	case "bloom":
+		capacity, rate := n.cfg.BloomCapacity, n.cfg.BloomFalsePositiveRate
+		if capacity == 0 {
+			capacity = defaultBloomCapacity
+		}
+		if rate <= 0 {
+			rate = defaultBloomFalsePositiveRate
+		}
+		visited = lib.NewBloomSet(capacity, rate)
+	default:
+		return nil, fmt.Errorf("unknown visited set: %s", n.cfg.VisitedSet)
+	}
+
+	switch n.cfg.Frontier {
+	case "", "memory":
+		return frontier.NewFrontier(n.cfg.RandomCrawl, visited), nil
+	case "disk":
+		if _, ok := visited.(lib.Set); !ok {
+			return nil, errors.New("the disk frontier only supports the exact visited set")
+		}
+		limit := n.cfg.FrontierMemoryLimit
+		if limit <= 0 {
+			limit = defaultFrontierMemoryLimit
+		}
+		return frontier.NewDiskFrontier(n.cfg.RandomCrawl, n.cfg.FrontierPath, limit)
+	default:
+		return nil, fmt.Errorf("unknown frontier: %s", n.cfg.Frontier)
+	}
+}
+
+// supervise moves the Nomad through the stopping and stopped states once ctx is done or
+// every worker has exited by itself.
+func (n *Nomad) supervise(ctx context.Context) {
+	workersDone := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(workersDone)
+	}()
+
+	var checkpointTick <-chan time.Time
+	if n.edges != nil && n.cfg.CheckpointInterval.Duration > 0 {
+		ticker := time.NewTicker(n.cfg.CheckpointInterval.Duration)
+		defer ticker.Stop()
+		checkpointTick = ticker.C
+	}
 
+loop:
 	for {
 		select {
-		case <-n.cancel:
+		case <-checkpointTick:
+			n.saveCheckpoint()
+		case <-ctx.Done():
+			n.mu.Lock()
+			n.state = StateStopping
+			n.mu.Unlock()
+			<-workersDone
+			break loop
+		case <-workersDone:
+			break loop
+		}
+	}
+
+	if n.edges != nil {
+		n.saveCheckpoint()
+	}
+	frontierStats := n.frontier.Stats()
+	if err := n.frontier.Close(); err != nil {
+		log.Printf("Could not close frontier, err: %v\n", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	// Release the context, this does nothing if it's already done.
+	n.stop()
+	n.state = StateStopped
+	n.summary.Stopped = time.Now()
+	n.summary.UrlsCrawled = n.urlsCrawled.Load()
+	n.summary.Hostnames = n.budget.hostnames()
+	n.summary.Frontier = frontierStats
+	// If nothing inside Nomad stopped the crawl it was the parent context, so pass on
+	// its reason.
+	if n.stopReason == "" {
+		n.stopReason = StopContextDone
+		n.err = context.Cause(ctx)
+	}
+	n.summary.StopReason = n.stopReason
+	close(n.done)
+}
+
+// stopWith stops a running crawl, recording reason as why it was stopped. n.mu must be
+// held.
+func (n *Nomad) stopWith(reason StopReason) {
+	if n.state != StateRunning {
+		return
+	}
+	n.state = StateStopping
+	n.stopReason = reason
+	n.stop()
+}
+
+// windDown stops a running crawl without cancelling in-flight requests, recording reason
+// as why it was stopped. Workers finish their current URL and then exit.
+func (n *Nomad) windDown(reason StopReason) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.state != StateRunning {
+		return
+	}
+	n.state = StateStopping
+	n.stopReason = reason
+	n.frontier.Stop()
+}
+
+// Cancel gracefully stops all the workers, cancelling any in-flight requests, and blocks
+// until all the goroutines are exited. It is safe to call at any time, from any
+// goroutine, and more than once. Cancelling an idle Nomad stops it from being run.
+func (n *Nomad) Cancel() {
+	n.mu.Lock()
+	switch n.state {
+	case StateIdle:
+		n.state = StateStopped
+		close(n.done)
+	case StateRunning:
+		n.stopWith(StopCancelled)
+	case StateStopping:
+		// The crawl may be winding down, don't wait for in-flight requests.
+		n.stop()
+	}
+	n.mu.Unlock()
+
+	<-n.done
+}
+
+// Done returns a channel that is closed once the crawl has stopped, including when it
+// finishes by itself because the frontier is exhausted.
+func (n *Nomad) Done() <-chan struct{} {
+	return n.done
+}
+
+// Wait blocks until the crawl has stopped and returns a summary of it. The error is
+// the parent context's error if that is what stopped the crawl, or nil otherwise.
+func (n *Nomad) Wait() (Summary, error) {
+	<-n.done
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.summary, n.err
+}
+
+func (n *Nomad) worker(ctx context.Context, id uint) {
+	defer n.wg.Done()
+
+	for {
+		log.Printf("{%d} Loop, frontier size: %d\n", id, n.frontier.Size())
+
+		current, err := n.frontier.PopUrl(ctx)
+		if errors.Is(err, frontier.ErrExhausted) {
+			log.Printf("{%d} Frontier exhausted\n", id)
+			if n.budget.depthLimited.Load() {
+				n.windDown(StopMaxDepth)
+			} else {
+				n.windDown(StopFrontierExhausted)
+			}
+			return
+		} else if err != nil {
 			log.Printf("{%d} Canceled\n", id)
 			return
-		default:
 		}
 
-		log.Printf("{%d} Loop, frontier size: %d\n", id, n.frontier.Size())
+		allowed, last := n.budget.reserveFetch()
+		if !allowed {
+			// Put it back so it isn't lost if the crawl is resumed.
+			n.frontier.Requeue(current)
+			n.windDown(StopMaxHostsFetched)
+			return
+		}
+
+		notifier, notify := n.graph.(graphs.CrawlNotifier)
+		hostname, err := getHostname(current.Url)
+		if notify = notify && err == nil; notify {
+			notifier.NotifyStartCrawl(id, hostname)
+		}
 
-		if currentlUrl := n.frontier.PopUrl(); currentlUrl != "" {
-			n.workOnUrl(id, currentlUrl)
+		connections, deadEnd, retry := n.workOnUrl(ctx, id, current)
+		if retry {
+			// The host wasn't really fetched, its retry uses the reservation instead.
+			n.budget.refundFetch()
+			current.Attempts++
+			n.frontier.Requeue(current)
 		} else {
-			log.Printf("{%d} Sleeping on empty frontier\n", id)
+			n.complete(current.Url, connections)
+		}
+		n.urlsCrawled.Add(1)
+
+		// Providers attribute connections to the worker crawling their hostname, so the
+		// end is only notified once they've been added.
+		if notify {
+			notifier.NotifyEndCrawl(id, hostname, deadEnd)
+		}
+
+		if last && !retry {
+			log.Printf("{%d} Reached max hosts fetched\n", id)
+			n.windDown(StopMaxHostsFetched)
+			return
 		}
 
-		time.Sleep(n.cfg.WorkerCooldown.Duration)
+		select {
+		case <-ctx.Done():
+		case <-time.After(n.cfg.WorkerCooldown.Duration):
+		}
 	}
 }
 
-func (n Nomad) workOnUrl(id uint, currentlUrl string) {
+// workOnUrl crawls a URL from the frontier, returning the connections found, true if no
+// URLs were found, and true if it should be retried.
+func (n *Nomad) workOnUrl(ctx context.Context, id uint, current frontier.Entry) (connections []graphs.Edge, deadEnd, retry bool) {
+	currentlUrl := current.Url
 	log.Printf("{%d} Processing URL %s\n", id, currentlUrl)
+	deadEnd = true
 
 	currentHostname, err := getHostname(currentlUrl)
 	if err != nil {
@@ -104,24 +386,49 @@ func (n Nomad) workOnUrl(id uint, currentlUrl string) {
 		return
 	}
 
-	var urls []string
+	header := http.Header{}
+	header.Set("User-Agent", uarand.GetRandom())
 
-	if g, ok := n.graph.(graphs.WebsocketGraphProvider); ok {
-		g.NotifyStartCrawl(id, currentHostname)
-		defer func() {
-			g.NotifyEndCrawl(id, currentHostname, len(urls) == 0)
-		}()
+	if n.cfg.RespectRobotsTxt {
+		if ok := n.checkRobots(ctx, id, currentHostname, currentlUrl, header); !ok {
+			return
+		}
 	}
 
-	urls, err = n.getUrls(currentlUrl)
-	if err != nil {
+	if err := n.scheduler.Wait(ctx, currentHostname); err != nil {
+		return
+	}
+
+	started := time.Now()
+	page, err := n.getUrls(ctx, currentlUrl, header)
+	urls := page.urls
+	deadEnd = len(urls) == 0
+	if g, ok := n.graph.(graphs.FetchRecorder); ok {
+		g.RecordFetch(graphs.FetchResult{
+			Url:        currentlUrl,
+			Hostname:   currentHostname,
+			WorkerId:   id,
+			Started:    started,
+			Duration:   time.Since(started),
+			StatusCode: page.status,
+			Links:      len(urls),
+			Err:        err,
+		})
+	}
+	if errors.Is(err, errSlowDown) && current.Attempts < n.cfg.MaxRetries {
+		log.Printf("{%d} Asked to slow down, will retry\n", id)
+		return nil, deadEnd, true
+	} else if err != nil {
 		log.Printf("{%d} Could not get URLs, err: %v\n", id, err)
 		return
 	}
 
 	log.Printf("{%d} Found %d URLs\n", id, len(urls))
 
-	for _, foundUrl := range urls {
+	edges := newPageEdges(currentHostname, page.url)
+
+	for _, found := range urls {
+		foundUrl := found.url
 		foundHostname, err := getHostname(foundUrl)
 		if err != nil {
 			log.Printf("{%d} Could not get found URLs hostname, err: %v\n", id, err)
@@ -140,39 +447,115 @@ func (n Nomad) workOnUrl(id uint, currentlUrl string) {
 			continue
 		}
 
-		// URL will be ignored by AddUrl if we've already seen it.
-		if added := n.frontier.AddUrl(foundHostnameAsUrl); added {
-			n.graph.AddHostnameConnection(currentHostname, foundHostname)
+		if !n.budget.discoverHostname(foundHostname) {
+			n.windDown(StopMaxHostnames)
+			continue
+		}
+
+		if found.kind == graphs.LinkTLSSan && !n.cfg.EnqueueCertificateNames {
+			// Only record the connection, certificate names aren't crawled.
+			if !n.cfg.DiscoveryTree {
+				edges.add(foundHostname, found)
+			}
+			continue
+		}
+
+		depth := current.Depth + 1
+		if n.budget.exceedsDepth(depth) {
+			// Still record the connection, we just won't crawl the host.
+			if !n.cfg.DiscoveryTree {
+				edges.add(foundHostname, found)
+			}
+			continue
 		}
+
+		// URL will be ignored by AddUrl if we've already seen it, but the connection is
+		// still recorded unless we only want the discovery tree.
+		added := n.frontier.AddUrl(foundHostnameAsUrl, depth)
+		if added || !n.cfg.DiscoveryTree {
+			edges.add(foundHostname, found)
+		}
+	}
+
+	return edges.edges, deadEnd, false
+}
+
+// checkRobots returns true if the host's robots.txt allows us to crawl it. Any
+// Crawl-delay it asks for is passed on to the scheduler.
+func (n *Nomad) checkRobots(ctx context.Context, id uint, hostname, hostUrl string, header http.Header) bool {
+	if err := n.scheduler.Wait(ctx, hostname); err != nil {
+		return false
+	}
+
+	rules, err := n.robots.Get(ctx, hostUrl, header)
+	if err != nil {
+		// If we can't get robots.txt we can't know if we're allowed.
+		log.Printf("{%d} Could not get robots.txt, err: %v\n", id, err)
+		return false
+	}
+
+	if !rules.Allowed("/") {
+		log.Printf("{%d} Disallowed by robots.txt\n", id)
+		return false
+	}
+
+	if delay := min(rules.CrawlDelay, maxCrawlDelay); delay > 0 {
+		log.Printf("{%d} Using %s robots.txt crawl delay\n", id, delay)
+		n.scheduler.SetHostDelay(hostname, delay)
 	}
+
+	return true
 }
 
-func (n Nomad) getUrls(urlStr string) ([]string, error) {
-	req, err := http.NewRequest("GET", urlStr, nil)
+// fetchedPage is what getUrls found.
+type fetchedPage struct {
+	url    string // The URL of the page, after any redirects on the same hostname.
+	urls   []foundUrl
+	status int // 0 if there was no response.
+}
+
+// getUrls fetches urlStr, following redirects on the same hostname, and returns the URLs
+// the page links to. A redirect to another hostname is returned as the only URL.
+func (n *Nomad) getUrls(ctx context.Context, urlStr string, header http.Header) (fetchedPage, error) {
+	page := fetchedPage{url: urlStr}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
 	if err != nil {
-		return nil, err
+		return page, err
 	}
-	req.Header.Set("User-Agent", uarand.GetRandom())
+	req.Header = header
 
 	resp, err := n.client.Do(req)
 	if err != nil {
-		return nil, err
+		return page, err
 	}
 	defer resp.Body.Close()
 
+	page.url = resp.Request.URL.String()
+	page.status = resp.StatusCode
+
+	if n.scheduler.Feedback(req.URL.Hostname(), resp.StatusCode, resp.Header.Get("Retry-After")) {
+		return page, errSlowDown
+	}
+
+	if found, ok := redirectUrl(resp); ok {
+		page.urls = []foundUrl{found}
+		return page, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("got non-OK status code: %v", resp.StatusCode)
+		return page, fmt.Errorf("got non-OK status code: %v", resp.StatusCode)
 	}
 
 	doc, err := html.Parse(resp.Body)
 	if err != nil {
-		return nil, err
+		return page, err
 	}
 
-	baseURL, err := url.Parse(urlStr)
-	if err != nil {
-		return nil, err
+	// Relative links are relative to the page that was redirected to.
+	page.urls = extractURLs(doc, resp.Request.URL, n.sources)
+	if n.sources[graphs.LinkTLSSan] {
+		page.urls = append(page.urls, certificateUrls(resp.TLS)...)
 	}
-
-	return extractURLs(doc, baseURL), nil
+	return page, nil
 }
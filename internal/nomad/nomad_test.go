@@ -0,0 +1,74 @@
+package nomad
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// eventGraph records the order it's notified in.
+type eventGraph struct {
+	mu     *sync.Mutex
+	events []string
+}
+
+var _ graphs.CrawlNotifier = (*eventGraph)(nil)
+
+func newEventGraph() *eventGraph {
+	return &eventGraph{mu: &sync.Mutex{}}
+}
+
+func (g *eventGraph) record(format string, a ...any) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.events = append(g.events, fmt.Sprintf(format, a...))
+}
+
+func (g *eventGraph) AddHostnameConnection(edge graphs.Edge) {
+	g.record("edge %s %s", edge.From, edge.To)
+}
+
+func (g *eventGraph) NotifyStartCrawl(workerId uint, hostname string) {
+	g.record("start %d %s", workerId, hostname)
+}
+
+func (g *eventGraph) NotifyEndCrawl(workerId uint, hostname string, deadEnd bool) {
+	g.record("end %d %s %t", workerId, hostname, deadEnd)
+}
+
+// newLinkServer serves a page linking to linked.example.
+func newLinkServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="http://linked.example/">linked</a>`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNotifyOrder(t *testing.T) {
+	server := newLinkServer(t)
+	g := newEventGraph()
+	n := NewNomad(Config{WorkerCount: 1, InitialUrls: []string{server.URL}, MaxHostsFetched: 1}, server.Client(), g)
+	if err := n.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Connections are added while the worker is still crawling their hostname.
+	expected := []string{
+		"start 1 127.0.0.1",
+		"edge 127.0.0.1 linked.example",
+		"end 1 127.0.0.1 false",
+	}
+	if !reflect.DeepEqual(g.events, expected) {
+		t.Errorf("got events %q, expected %q", g.events, expected)
+	}
+}
@@ -0,0 +1,32 @@
+package nomad
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// checkRedirect is used as the HTTP client's CheckRedirect. It stops following after
+// Config.MaxRedirects, and doesn't follow redirects to other hostnames so they go
+// through the frontier, robots.txt and the scheduler like any other host.
+func (n *Nomad) checkRedirect(req *http.Request, via []*http.Request) error {
+	maxRedirects := n.cfg.maxRedirects()
+	if len(via) > maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Hostname() != via[len(via)-1].URL.Hostname() {
+		return http.ErrUseLastResponse
+	}
+	return nil
+}
+
+// redirectUrl returns the URL resp redirects to if it's on a different hostname, which
+// checkRedirect won't have followed. ok is false if resp isn't one of those redirects.
+func redirectUrl(resp *http.Response) (found foundUrl, ok bool) {
+	location, err := resp.Location()
+	if err != nil || location.Hostname() == resp.Request.URL.Hostname() {
+		return foundUrl{}, false
+	}
+	return foundUrl{url: location.String(), kind: graphs.LinkRedirect}, true
+}
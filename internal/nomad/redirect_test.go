@@ -0,0 +1,102 @@
+package nomad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+func newRequest(t *testing.T, rawUrl string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, rawUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestCheckRedirect(t *testing.T) {
+	n := NewNomad(Config{MaxRedirects: 2}, &http.Client{}, graphs.NewRecorder())
+	first := newRequest(t, "http://a.example/")
+
+	if err := n.checkRedirect(newRequest(t, "https://a.example/next"), []*http.Request{first}); err != nil {
+		t.Errorf("same hostname: got %v, expected it to be followed", err)
+	}
+	if err := n.checkRedirect(newRequest(t, "http://b.example/"), []*http.Request{first}); !errors.Is(err, http.ErrUseLastResponse) {
+		t.Errorf("other hostname: got %v, expected ErrUseLastResponse", err)
+	}
+
+	via := []*http.Request{first, first, first}
+	if err := n.checkRedirect(newRequest(t, "http://a.example/"), via); err == nil || errors.Is(err, http.ErrUseLastResponse) {
+		t.Errorf("too many redirects: got %v, expected an error", err)
+	}
+}
+
+func TestRedirectUrl(t *testing.T) {
+	for _, test := range []struct {
+		location string
+		expected string
+	}{
+		{location: "", expected: ""},
+		{location: "/moved", expected: ""},
+		{location: "https://a.example/", expected: ""},
+		{location: "https://b.example/path", expected: "https://b.example/path"},
+		{location: "//c.example/", expected: "http://c.example/"},
+	} {
+		resp := &http.Response{
+			StatusCode: http.StatusMovedPermanently,
+			Header:     http.Header{},
+			Request:    newRequest(t, "http://a.example/page"),
+		}
+		if test.location != "" {
+			resp.Header.Set("Location", test.location)
+		}
+
+		found, ok := redirectUrl(resp)
+		if ok != (test.expected != "") || found.url != test.expected {
+			t.Errorf("%q: got %q %t, expected %q", test.location, found.url, ok, test.expected)
+			continue
+		}
+		if ok && found.kind != graphs.LinkRedirect {
+			t.Errorf("%q: got kind %s, expected %s", test.location, found.kind, graphs.LinkRedirect)
+		}
+	}
+}
+
+func TestRobotsRedirectToOtherHostname(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path != "/robots.txt":
+			fmt.Fprint(w, `<a href="http://linked.example/">linked</a>`)
+		case strings.HasPrefix(r.Host, "127.0.0.1"):
+			// e.g. http://example.com/robots.txt -> https://www.example.com/robots.txt
+			u, _ := url.Parse(server.URL)
+			http.Redirect(w, r, "http://localhost:"+u.Port()+"/robots.txt", http.StatusMovedPermanently)
+		default:
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		}
+	}))
+	defer server.Close()
+
+	g := newEventGraph()
+	cfg := Config{WorkerCount: 1, InitialUrls: []string{server.URL}, MaxHostsFetched: 1, RespectRobotsTxt: true}
+	n := NewNomad(cfg, server.Client(), g)
+	if err := n.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	n.Wait()
+
+	for _, event := range g.events {
+		if event == "edge 127.0.0.1 linked.example" {
+			return
+		}
+	}
+	t.Errorf("the host wasn't crawled, got events %q", g.events)
+}
@@ -2,28 +2,128 @@ package nomad
 
 import (
 	"net/url"
+	"slices"
+	"strings"
 
 	"golang.org/x/net/html"
+
+	"github.com/psidex/nomad/internal/graphs"
 )
 
-// extractURLs gets all hrefs from all <a> tags in a html document as absolute URLs.
-func extractURLs(n *html.Node, baseURL *url.URL) []string {
-	var urls []string
+// maxAnchorText is how many characters of a link's text are kept.
+const maxAnchorText = 200
+
+// foundUrl is an absolute URL found in a page, and where in the page it was found.
+type foundUrl struct {
+	url  string
+	kind graphs.LinkKind
+	text string   // The anchor text, only for <a>.
+	rel  []string // The rel values, only for <a>, <link>, and <form>.
+}
+
+// pageEdges groups the links found in a single page in to one graphs.Edge for each
+// hostname and kind, in the order they were first found.
+type pageEdges struct {
+	fromHost  string
+	sourceUrl string
+	edges     []graphs.Edge
+	index     map[pageEdgeKey]int
+}
+
+type pageEdgeKey struct {
+	toHost string
+	kind   graphs.LinkKind
+}
+
+func newPageEdges(fromHost, sourceUrl string) *pageEdges {
+	return &pageEdges{fromHost: fromHost, sourceUrl: sourceUrl, index: make(map[pageEdgeKey]int)}
+}
+
+func (p *pageEdges) add(toHost string, found foundUrl) {
+	key := pageEdgeKey{toHost: toHost, kind: found.kind}
+	i, ok := p.index[key]
+	if !ok {
+		i = len(p.edges)
+		p.index[key] = i
+		p.edges = append(p.edges, graphs.Edge{
+			From:      p.fromHost,
+			To:        toHost,
+			Kind:      found.kind,
+			SourceUrl: p.sourceUrl,
+		})
+	}
+
+	edge := &p.edges[i]
+	edge.Count++
+	if edge.AnchorText == "" {
+		edge.AnchorText = found.text
+	}
+	for _, rel := range found.rel {
+		if !slices.Contains(edge.Rel, rel) {
+			edge.Rel = append(edge.Rel, rel)
+		}
+	}
+}
+
+// extractURLs gets every http(s) URL from the parts of a html document enabled in
+// sources, as absolute URLs. Relative URLs are resolved against the document's <base
+// href> if it has one, or baseURL if it doesn't.
+func extractURLs(n *html.Node, baseURL *url.URL, sources map[graphs.LinkKind]bool) []foundUrl {
+	var urls []foundUrl
+	baseURL = documentBase(n, baseURL)
+
+	add := func(rawURL string, kind graphs.LinkKind) *foundUrl {
+		rawURL = strings.TrimSpace(rawURL)
+		if rawURL == "" {
+			return nil
+		}
+		parsedURL, err := url.Parse(rawURL)
+		if err != nil {
+			return nil
+		}
+		// If parsedURL is an absolute URL, parsedURL is returned
+		// Else, resolve the relative URL to an absolute using baseURL
+		absoluteURL := baseURL.ResolveReference(parsedURL)
+		if absoluteURL.Scheme != "http" && absoluteURL.Scheme != "https" {
+			return nil
+		}
+		urls = append(urls, foundUrl{url: absoluteURL.String(), kind: kind})
+		return &urls[len(urls)-1]
+	}
 
 	var visitNode func(*html.Node)
 	visitNode = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			for _, attr := range n.Attr {
-				if attr.Key == "href" {
-					parsedURL, err := url.Parse(attr.Val)
-					if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
-						break
-					}
-					// If parsedURL is an absolute URL, parsedURL is returned
-					// Else, resolve the relative URL to an absolute using baseURL
-					absoluteURL := baseURL.ResolveReference(parsedURL).String()
-					urls = append(urls, absoluteURL)
-					break
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "a" && sources[graphs.LinkAnchor]:
+				if found := add(getAttr(n, "href"), graphs.LinkAnchor); found != nil {
+					found.text = anchorText(n)
+					found.rel = parseRel(getAttr(n, "rel"))
+				}
+			case n.Data == "link" && sources[graphs.LinkLink]:
+				if found := add(getAttr(n, "href"), graphs.LinkLink); found != nil {
+					found.rel = parseRel(getAttr(n, "rel"))
+				}
+			case n.Data == "script" && sources[graphs.LinkScript]:
+				add(getAttr(n, "src"), graphs.LinkScript)
+			case (n.Data == "img" || n.Data == "source") && sources[graphs.LinkImage]:
+				add(getAttr(n, "src"), graphs.LinkImage)
+				for _, candidate := range parseSrcset(getAttr(n, "srcset")) {
+					add(candidate, graphs.LinkImage)
+				}
+			case n.Data == "iframe" && sources[graphs.LinkIframe]:
+				add(getAttr(n, "src"), graphs.LinkIframe)
+			case n.Data == "form" && sources[graphs.LinkForm]:
+				if found := add(getAttr(n, "action"), graphs.LinkForm); found != nil {
+					found.rel = parseRel(getAttr(n, "rel"))
+				}
+			case n.Data == "meta":
+				if sources[graphs.LinkMetaRefresh] && strings.EqualFold(getAttr(n, "http-equiv"), "refresh") {
+					add(parseRefresh(getAttr(n, "content")), graphs.LinkMetaRefresh)
+				}
+				// Most social meta tags aren't URLs, e.g. og:title, so they have to be absolute.
+				if content := getAttr(n, "content"); sources[graphs.LinkMeta] && isSocialMeta(n) && isAbsolute(content) {
+					add(content, graphs.LinkMeta)
 				}
 			}
 		}
@@ -37,3 +137,100 @@ func extractURLs(n *html.Node, baseURL *url.URL) []string {
 
 	return urls
 }
+
+// documentBase returns the URL from the first <base href> in the document, resolved
+// against baseURL, or baseURL if there isn't one.
+func documentBase(n *html.Node, baseURL *url.URL) *url.URL {
+	if n.Type == html.ElementNode && n.Data == "base" {
+		if href := strings.TrimSpace(getAttr(n, "href")); href != "" {
+			if parsed, err := url.Parse(href); err == nil {
+				return baseURL.ResolveReference(parsed)
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if base := documentBase(c, baseURL); base != baseURL {
+			return base
+		}
+	}
+	return baseURL
+}
+
+// getAttr returns the value of the attribute, or "" if it doesn't have it.
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// anchorText returns the whitespace collapsed text inside n, up to maxAnchorText
+// characters.
+func anchorText(n *html.Node) string {
+	var b strings.Builder
+	var visitNode func(*html.Node)
+	visitNode = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visitNode(c)
+		}
+	}
+	visitNode(n)
+
+	text := strings.Join(strings.Fields(b.String()), " ")
+	if runes := []rune(text); len(runes) > maxAnchorText {
+		text = string(runes[:maxAnchorText])
+	}
+	return text
+}
+
+// parseRel returns the lowercased values of a rel attribute, e.g. "nofollow noopener".
+func parseRel(rel string) []string {
+	return strings.Fields(strings.ToLower(rel))
+}
+
+// parseSrcset returns the URLs from a srcset attribute, e.g. "a.png 1x, b.png 2x".
+func parseSrcset(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// parseRefresh returns the URL from a meta refresh's content, e.g. "5; url=/next".
+func parseRefresh(content string) string {
+	_, target, ok := strings.Cut(content, ";")
+	if !ok {
+		return ""
+	}
+	target = strings.TrimSpace(target)
+	if len(target) >= 4 && strings.EqualFold(target[:4], "url=") {
+		target = target[4:]
+	}
+	return strings.Trim(target, `'" `)
+}
+
+// isAbsolute returns true if rawURL is an absolute http(s) URL, or scheme relative.
+func isAbsolute(rawURL string) bool {
+	rawURL = strings.ToLower(strings.TrimSpace(rawURL))
+	return strings.HasPrefix(rawURL, "http://") || strings.HasPrefix(rawURL, "https://") || strings.HasPrefix(rawURL, "//")
+}
+
+// isSocialMeta returns true for Open Graph (og:) and Twitter card (twitter:) meta tags.
+func isSocialMeta(n *html.Node) bool {
+	for _, key := range []string{"property", "name"} {
+		value := strings.ToLower(getAttr(n, key))
+		if strings.HasPrefix(value, "og:") || strings.HasPrefix(value, "twitter:") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package nomad
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+func parseHtml(t *testing.T, doc string) *html.Node {
+	t.Helper()
+	n, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// foundUrls returns each found URL as "kind url".
+func foundUrls(urls []foundUrl) []string {
+	var found []string
+	for _, u := range urls {
+		found = append(found, string(u.kind)+" "+u.url)
+	}
+	return found
+}
+
+func TestParseSrcset(t *testing.T) {
+	for _, test := range []struct {
+		srcset   string
+		expected []string
+	}{
+		{"", nil},
+		{"a.png", []string{"a.png"}},
+		{"a.png 1x, b.png 2x", []string{"a.png", "b.png"}},
+		{"  a.png   480w,\n\tb.png 800w  ", []string{"a.png", "b.png"}},
+		{"a.png 1x,, ,b.png", []string{"a.png", "b.png"}},
+		{"https://cdn.example/a.png 1x", []string{"https://cdn.example/a.png"}},
+	} {
+		if got := parseSrcset(test.srcset); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q: got %q, expected %q", test.srcset, got, test.expected)
+		}
+	}
+}
+
+func TestParseRefresh(t *testing.T) {
+	for _, test := range []struct {
+		content  string
+		expected string
+	}{
+		{"", ""},
+		{"5", ""},
+		{"0; url=https://a.example/", "https://a.example/"},
+		{"0;URL=/next", "/next"},
+		{"5; url='https://a.example/'", "https://a.example/"},
+		{`5; URL="/quoted"`, "/quoted"},
+		{"3;https://b.example/", "https://b.example/"},
+	} {
+		if got := parseRefresh(test.content); got != test.expected {
+			t.Errorf("%q: got %q, expected %q", test.content, got, test.expected)
+		}
+	}
+}
+
+func TestIsSocialMeta(t *testing.T) {
+	for _, test := range []struct {
+		tag      string
+		expected bool
+	}{
+		{`<meta property="og:image" content="x">`, true},
+		{`<meta property="OG:URL" content="x">`, true},
+		{`<meta name="twitter:image" content="x">`, true},
+		{`<meta name="description" content="x">`, false},
+		{`<meta http-equiv="refresh" content="x">`, false},
+		{`<meta property="ogimage" content="x">`, false},
+	} {
+		var meta *html.Node
+		var find func(*html.Node)
+		find = func(n *html.Node) {
+			if n.Type == html.ElementNode && n.Data == "meta" {
+				meta = n
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				find(c)
+			}
+		}
+		find(parseHtml(t, test.tag))
+
+		if got := isSocialMeta(meta); got != test.expected {
+			t.Errorf("%s: got %t, expected %t", test.tag, got, test.expected)
+		}
+	}
+}
+
+// sourcesPage has one link for each link source.
+const sourcesPage = `<html><head>
+<link rel="stylesheet" href="https://link.example/style.css">
+<script src="https://script.example/app.js"></script>
+<meta http-equiv="refresh" content="30; url=https://refresh.example/">
+<meta property="og:image" content="https://meta.example/card.png">
+<meta property="og:title" content="not a url">
+</head><body>
+<a href="https://anchor.example/" rel="nofollow">An  anchor</a>
+<img src="https://img.example/a.png" srcset="https://img.example/b.png 2x">
+<picture><source srcset="https://source.example/c.webp"></picture>
+<iframe src="https://iframe.example/"></iframe>
+<form action="https://form.example/submit"></form>
+<a href="mailto:someone@example.com">mail</a>
+<a href="javascript:void(0)">js</a>
+</body></html>`
+
+func TestExtractURLsSources(t *testing.T) {
+	base, _ := url.Parse("https://page.example/")
+	doc := parseHtml(t, sourcesPage)
+
+	for _, test := range []struct {
+		source   graphs.LinkKind
+		expected []string
+	}{
+		{graphs.LinkAnchor, []string{"a https://anchor.example/"}},
+		{graphs.LinkLink, []string{"link https://link.example/style.css"}},
+		{graphs.LinkScript, []string{"script https://script.example/app.js"}},
+		{graphs.LinkImage, []string{"img https://img.example/a.png", "img https://img.example/b.png", "img https://source.example/c.webp"}},
+		{graphs.LinkIframe, []string{"iframe https://iframe.example/"}},
+		{graphs.LinkForm, []string{"form https://form.example/submit"}},
+		{graphs.LinkMetaRefresh, []string{"meta-refresh https://refresh.example/"}},
+		{graphs.LinkMeta, []string{"meta https://meta.example/card.png"}},
+		{graphs.LinkTLSSan, nil},
+	} {
+		got := foundUrls(extractURLs(doc, base, map[graphs.LinkKind]bool{test.source: true}))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: got %q, expected %q", test.source, got, test.expected)
+		}
+	}
+
+	urls := extractURLs(doc, base, map[graphs.LinkKind]bool{graphs.LinkAnchor: true})
+	if urls[0].text != "An anchor" || !reflect.DeepEqual(urls[0].rel, []string{"nofollow"}) {
+		t.Errorf("got text %q and rel %q, expected the anchor's", urls[0].text, urls[0].rel)
+	}
+}
+
+func TestExtractURLsRelative(t *testing.T) {
+	base, _ := url.Parse("https://page.example/dir/page.html")
+	anchors := map[graphs.LinkKind]bool{graphs.LinkAnchor: true}
+
+	for _, test := range []struct {
+		doc      string
+		expected []string
+	}{
+		{`<a href="other.html">`, []string{"a https://page.example/dir/other.html"}},
+		{`<a href="/root">`, []string{"a https://page.example/root"}},
+		{`<a href="//cdn.example/x">`, []string{"a https://cdn.example/x"}},
+		{`<a href="  https://padded.example/  ">`, []string{"a https://padded.example/"}},
+		{`<a href="">`, nil},
+		{`<head><base href="https://base.example/sub/"></head><a href="other.html">`, []string{"a https://base.example/sub/other.html"}},
+		{`<head><base href="/other/"></head><a href="x">`, []string{"a https://page.example/other/x"}},
+		{`<head><base target="_blank"><base href="/second/"></head><a href="x">`, []string{"a https://page.example/second/x"}},
+	} {
+		got := foundUrls(extractURLs(parseHtml(t, test.doc), base, anchors))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: got %q, expected %q", test.doc, got, test.expected)
+		}
+	}
+}
+
+func TestGetUrlsRelativeAfterRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/dir/page", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, `<a href="other">relative</a>`)
+	}))
+	defer server.Close()
+
+	n := newTestNomad(Config{}, server.Client())
+	page, err := n.getUrls(context.Background(), server.URL+"/", http.Header{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page.url != server.URL+"/dir/page" {
+		t.Errorf("got page URL %s, expected the one redirected to", page.url)
+	}
+	expected := []string{"a " + server.URL + "/dir/other"}
+	if got := foundUrls(page.urls); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,77 @@
+package nomad
+
+import (
+	"errors"
+	"time"
+
+	"github.com/psidex/nomad/internal/frontier"
+)
+
+// State is the lifecycle state of a Nomad. A Nomad moves through the states in order
+// and can only be run once.
+type State int
+
+const (
+	// StateIdle means Run has not been called yet.
+	StateIdle State = iota
+	// StateRunning means the workers are crawling.
+	StateRunning
+	// StateStopping means the crawl has been asked to stop and the workers are
+	// finishing up.
+	StateStopping
+	// StateStopped means every worker has exited, or the Nomad was cancelled before it
+	// was run.
+	StateStopped
+)
+
+func (s State) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrAlreadyRun is returned by Run if it has been called before, or if the Nomad was
+// cancelled before being run.
+var ErrAlreadyRun = errors.New("nomad has already been run")
+
+// StopReason describes why a crawl stopped.
+type StopReason string
+
+const (
+	// StopCancelled means Cancel was called.
+	StopCancelled StopReason = "cancelled"
+	// StopContextDone means the context given to Run was done.
+	StopContextDone StopReason = "context done"
+	// StopFrontierExhausted means every reachable hostname was crawled.
+	StopFrontierExhausted StopReason = "frontier exhausted"
+	// StopMaxHostsFetched means Config.MaxHostsFetched hosts were fetched.
+	StopMaxHostsFetched StopReason = "max hosts fetched"
+	// StopMaxHostnames means Config.MaxHostnames hostnames were discovered.
+	StopMaxHostnames StopReason = "max hostnames"
+	// StopMaxDepth means the frontier was exhausted but hosts beyond Config.MaxDepth
+	// were left uncrawled.
+	StopMaxDepth StopReason = "max depth"
+)
+
+// Summary describes a finished crawl.
+type Summary struct {
+	Started     time.Time  `json:"started"`
+	Stopped     time.Time  `json:"stopped"`
+	StopReason  StopReason `json:"stopReason"`
+	UrlsCrawled int64      `json:"urlsCrawled"`
+	// Hostnames is how many distinct hostnames were discovered, it's only counted if
+	// Config.MaxHostnames is set.
+	Hostnames int `json:"hostnames"`
+	// Frontier is the state of the frontier when the crawl stopped, including the
+	// estimated error if a probabilistic visited set was used.
+	Frontier frontier.Stats `json:"frontier"`
+}
@@ -0,0 +1,163 @@
+package nomad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/psidex/nomad/internal/graphs"
+)
+
+// newBlockingServer serves requests that don't finish until they're cancelled, started
+// receives each request's path.
+func newBlockingServer(t *testing.T) (server *httptest.Server, started chan string) {
+	started = make(chan string, 10)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- r.URL.Path
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return server, started
+}
+
+// isDone returns true if ch is closed.
+func isDone(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestCancelIdle(t *testing.T) {
+	n := NewNomad(Config{WorkerCount: 1, InitialUrls: []string{"http://a.example"}}, &http.Client{}, graphs.NewRecorder())
+	if n.State() != StateIdle {
+		t.Fatalf("state is %s, expected idle", n.State())
+	}
+
+	n.Cancel()
+	if n.State() != StateStopped || !isDone(n.Done()) {
+		t.Errorf("state is %s and done is %t after cancelling, expected stopped and done", n.State(), isDone(n.Done()))
+	}
+	if err := n.Run(context.Background()); !errors.Is(err, ErrAlreadyRun) {
+		t.Errorf("Run returned %v after cancelling, expected ErrAlreadyRun", err)
+	}
+	// Cancelling again is allowed.
+	n.Cancel()
+}
+
+func TestCancelRunning(t *testing.T) {
+	server, started := newBlockingServer(t)
+	n := NewNomad(Config{WorkerCount: 1, InitialUrls: []string{server.URL}}, server.Client(), graphs.NewRecorder())
+	if err := n.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Run(context.Background()); !errors.Is(err, ErrAlreadyRun) {
+		t.Errorf("Run returned %v the second time, expected ErrAlreadyRun", err)
+	}
+	if n.State() != StateRunning {
+		t.Errorf("state is %s, expected running", n.State())
+	}
+
+	// Cancel once a request is in flight, it shouldn't wait for it to finish.
+	<-started
+	if isDone(n.Done()) {
+		t.Fatal("done before being cancelled")
+	}
+	n.Cancel()
+	if n.State() != StateStopped || !isDone(n.Done()) {
+		t.Errorf("state is %s and done is %t after cancelling, expected stopped and done", n.State(), isDone(n.Done()))
+	}
+
+	summary, err := n.Wait()
+	if err != nil || summary.StopReason != StopCancelled {
+		t.Errorf("stopped by %q with %v, expected %q", summary.StopReason, err, StopCancelled)
+	}
+	if summary.Started.IsZero() || summary.Stopped.Before(summary.Started) {
+		t.Errorf("summary runs from %s to %s", summary.Started, summary.Stopped)
+	}
+	n.Cancel()
+}
+
+func TestContextDone(t *testing.T) {
+	server, _ := newBlockingServer(t)
+	n := NewNomad(Config{WorkerCount: 2, InitialUrls: []string{server.URL}}, server.Client(), graphs.NewRecorder())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := n.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := n.Wait()
+	if !errors.Is(err, context.DeadlineExceeded) || summary.StopReason != StopContextDone {
+		t.Errorf("stopped by %q with %v, expected %q with the deadline", summary.StopReason, err, StopContextDone)
+	}
+	if n.State() != StateStopped {
+		t.Errorf("state is %s, expected stopped", n.State())
+	}
+}
+
+// newChainServer serves a chain of hostnames, 127.0.0.1 links to localhost, which links to
+// end.example. Other hostnames in the page are ignored.
+func newChainServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Host, "127.0.0.1") {
+			fmt.Fprintf(w, `<a href="%s">next</a>`, strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+			return
+		}
+		fmt.Fprint(w, `<a href="http://end.example/">end</a>`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStopReasons(t *testing.T) {
+	emptyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer emptyServer.Close()
+	linkServer := newLinkServer(t)
+	chainServer := newChainServer(t)
+
+	for _, test := range []struct {
+		name     string
+		server   *httptest.Server
+		cfg      Config
+		expected StopReason
+	}{
+		{"exhausted", emptyServer, Config{}, StopFrontierExhausted},
+		{"max hosts fetched", linkServer, Config{MaxHostsFetched: 1}, StopMaxHostsFetched},
+		{"max hostnames", linkServer, Config{MaxHostnames: 1}, StopMaxHostnames},
+		{"max depth", chainServer, Config{MaxDepth: 1}, StopMaxDepth},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := test.cfg
+			cfg.WorkerCount = 2
+			cfg.InitialUrls = []string{test.server.URL}
+			n := NewNomad(cfg, test.server.Client(), graphs.NewRecorder())
+			if err := n.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-n.Done():
+			case <-time.After(10 * time.Second):
+				n.Cancel()
+				t.Fatal("the crawl didn't stop by itself")
+			}
+			summary, err := n.Wait()
+			if err != nil || summary.StopReason != test.expected {
+				t.Errorf("stopped by %q with %v, expected %q", summary.StopReason, err, test.expected)
+			}
+			if n.State() != StateStopped {
+				t.Errorf("state is %s, expected stopped", n.State())
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rule is a single Allow or Disallow line from a robots.txt group.
+type rule struct {
+	allow bool
+	path  string
+}
+
+// group is a set of rules that apply to one or more user agents.
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+// Rules holds the rules from a robots.txt file that apply to a specific user agent.
+type Rules struct {
+	rules []rule
+	// CrawlDelay is the Crawl-delay requested for the user agent, or 0 if none was set.
+	CrawlDelay time.Duration
+}
+
+// AllowAll is used when a host has no usable robots.txt.
+var AllowAll = &Rules{}
+
+// DisallowAll is used when a host's robots.txt couldn't be fetched due to a server
+// error, in which case we have to assume we aren't welcome.
+var DisallowAll = &Rules{rules: []rule{{allow: false, path: "/"}}}
+
+// Parse reads a robots.txt file and returns the Rules that apply to userAgent, which is
+// a product token like "nomad" (RFC 9309 section 2.2.1). Groups are matched by the whole
+// token, ignoring case, and if no group names userAgent the "*" group is used.
+func Parse(r io.Reader, userAgent string) (*Rules, error) {
+	groups, err := parseGroups(r)
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent = strings.ToLower(userAgent)
+
+	chosen := groupsFor(groups, userAgent)
+	if chosen == nil {
+		chosen = groupsFor(groups, "*")
+	}
+
+	// Groups for the same agent are combined.
+	rules := &Rules{}
+	for _, g := range chosen {
+		rules.rules = append(rules.rules, g.rules...)
+		if g.crawlDelay > rules.CrawlDelay {
+			rules.CrawlDelay = g.crawlDelay
+		}
+	}
+	return rules, nil
+}
+
+// groupsFor returns every group that names agent, which must be lower case.
+func groupsFor(groups []*group, agent string) []*group {
+	var matched []*group
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a == agent {
+				matched = append(matched, g)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func parseGroups(r io.Reader) ([]*group, error) {
+	var groups []*group
+	var current *group
+	// A group is started by one or more consecutive User-agent lines.
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			// An empty User-agent doesn't name anyone, but still starts the group.
+			if agent := productToken(value); agent != "" {
+				current.agents = append(current.agents, agent)
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if value == "" {
+				// An empty Disallow means everything is allowed, and an empty Allow is
+				// meaningless.
+				continue
+			}
+			current.rules = append(current.rules, rule{allow: key == "allow", path: value})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			// Sitemap and other extensions don't belong to a group.
+		}
+	}
+
+	return groups, scanner.Err()
+}
+
+// productToken returns the lower case product token at the start of a User-agent value,
+// e.g. "nomad" from "Nomad/1.0", or "*".
+func productToken(value string) string {
+	if strings.HasPrefix(value, "*") {
+		return "*"
+	}
+	end := strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_' || r == '-')
+	})
+	if end >= 0 {
+		value = value[:end]
+	}
+	return strings.ToLower(value)
+}
+
+// Allowed reports whether path may be crawled. The longest matching rule wins, and
+// Allow wins a tie.
+func (r *Rules) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allowed := true
+	matchLen := -1
+	for _, rl := range r.rules {
+		if !matches(rl.path, path) {
+			continue
+		}
+		if len(rl.path) > matchLen || (len(rl.path) == matchLen && rl.allow) {
+			allowed, matchLen = rl.allow, len(rl.path)
+		}
+	}
+	return allowed
+}
+
+// matches reports if path matches pattern, which may contain the * wildcard and be
+// anchored to the end with $.
+func matches(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+
+	return !anchored || rest == ""
+}
@@ -0,0 +1,160 @@
+package robots
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseChoosesGroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		robots   string
+		path     string
+		expected bool
+	}{
+		{
+			name:     "falls back to star",
+			robots:   "User-agent: other\nDisallow: /\n\nUser-agent: *\nDisallow: /private",
+			path:     "/",
+			expected: true,
+		},
+		{
+			name:     "own group wins over star",
+			robots:   "User-agent: *\nDisallow: /\n\nUser-agent: nomad\nAllow: /",
+			path:     "/",
+			expected: true,
+		},
+		{
+			name:     "agent is case insensitive",
+			robots:   "User-agent: *\nAllow: /\n\nUser-agent: NoMaD\nDisallow: /",
+			path:     "/",
+			expected: false,
+		},
+		{
+			name:     "agent version is ignored",
+			robots:   "User-agent: *\nAllow: /\n\nUser-agent: Nomad/1.0\nDisallow: /",
+			path:     "/",
+			expected: false,
+		},
+		{
+			name:     "substring of agent doesn't match",
+			robots:   "User-agent: mad\nDisallow: /\n\nUser-agent: *\nAllow: /",
+			path:     "/",
+			expected: true,
+		},
+		{
+			name:     "longer agent doesn't match",
+			robots:   "User-agent: nomadbot\nDisallow: /\n\nUser-agent: *\nAllow: /",
+			path:     "/",
+			expected: true,
+		},
+		{
+			name:     "empty agent doesn't match",
+			robots:   "User-agent:\nDisallow: /\n\nUser-agent: *\nAllow: /",
+			path:     "/",
+			expected: true,
+		},
+		{
+			name:     "groups for the same agent are combined",
+			robots:   "User-agent: nomad\nDisallow: /a\n\nUser-agent: *\nDisallow: /\n\nUser-agent: nomad\nDisallow: /b",
+			path:     "/b/c",
+			expected: false,
+		},
+		{
+			name:     "consecutive agents share a group",
+			robots:   "User-agent: other\nUser-agent: nomad\nDisallow: /",
+			path:     "/",
+			expected: false,
+		},
+		{
+			name:     "no groups allows everything",
+			robots:   "Sitemap: https://example.com/sitemap.xml",
+			path:     "/",
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := Parse(strings.NewReader(test.robots), "nomad")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allowed := rules.Allowed(test.path); allowed != test.expected {
+				t.Errorf("Allowed(%q) = %v, expected %v", test.path, allowed, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseCrawlDelay(t *testing.T) {
+	robots := "User-agent: nomad\nCrawl-delay: 1.5\n\nUser-agent: *\nCrawl-delay: 10"
+	rules, err := Parse(strings.NewReader(robots), "nomad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Millisecond * 1500; rules.CrawlDelay != expected {
+		t.Errorf("CrawlDelay = %s, expected %s", rules.CrawlDelay, expected)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	robots := `User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Disallow: /search*results
+Allow: /page
+Disallow: /page # comment
+`
+	rules, err := Parse(strings.NewReader(robots), "nomad")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"", true},
+		{"/", true},
+		{"/private", false},
+		{"/private/secret", false},
+		{"/private/public/file", true},
+		{"/files/doc.pdf", false},
+		{"/files/doc.pdf?download", true},
+		{"/search/all/results", false},
+		{"/search/all", true},
+		{"/page", true}, // Allow wins a tie.
+	}
+
+	for _, test := range tests {
+		if allowed := rules.Allowed(test.path); allowed != test.expected {
+			t.Errorf("Allowed(%q) = %v, expected %v", test.path, allowed, test.expected)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		expected      bool
+	}{
+		{"/", "/anything", true},
+		{"/a", "/b", false},
+		{"/a*c", "/abc", true},
+		{"/a*c", "/ab", false},
+		{"/a$", "/a", true},
+		{"/a$", "/ab", false},
+		{"/*.js$", "/x/y.js", true},
+		{"/*.js$", "/x/y.json", false},
+		{"*", "/", true},
+	}
+
+	for _, test := range tests {
+		if matched := matches(test.pattern, test.path); matched != test.expected {
+			t.Errorf("matches(%q, %q) = %v, expected %v", test.pattern, test.path, matched, test.expected)
+		}
+	}
+}
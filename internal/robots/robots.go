@@ -0,0 +1,91 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// maxSize is the most of a robots.txt file that will be read, anything after this is
+// ignored (this is the same limit Google uses).
+const maxSize = 500 * 1024
+
+// Cache fetches and caches the robots.txt rules for each host. Cache is thread-safe and
+// should be held as a pointer.
+type Cache struct {
+	client    *http.Client
+	userAgent string
+	mu        *sync.Mutex
+	rules     map[string]*Rules
+}
+
+// NewCache creates a new Cache. userAgent is the name used to find the matching group
+// in each robots.txt, not the User-Agent header that is sent.
+func NewCache(client *http.Client, userAgent string) *Cache {
+	return &Cache{
+		client:    client,
+		userAgent: userAgent,
+		mu:        &sync.Mutex{},
+		rules:     make(map[string]*Rules),
+	}
+}
+
+// Get returns the rules for the scheme and host of hostUrl, fetching /robots.txt if
+// it hasn't been seen before. header is used for the robots.txt request.
+func (c *Cache) Get(ctx context.Context, hostUrl string, header http.Header) (*Rules, error) {
+	parsed, err := url.Parse(hostUrl)
+	if err != nil {
+		return nil, err
+	}
+	robotsUrl := &url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: "/robots.txt"}
+	key := robotsUrl.String()
+
+	c.mu.Lock()
+	rules, ok := c.rules[key]
+	c.mu.Unlock()
+	if ok {
+		return rules, nil
+	}
+
+	rules, err = c.fetch(ctx, robotsUrl.String(), header)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.rules[key] = rules
+	c.mu.Unlock()
+
+	return rules, nil
+}
+
+func (c *Cache) fetch(ctx context.Context, robotsUrl string, header http.Header) (*Rules, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// See RFC 9309 section 2.3.1 for how each status should be handled.
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return Parse(io.LimitReader(resp.Body, maxSize), c.userAgent)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return AllowAll, nil
+	case resp.StatusCode >= 500:
+		return DisallowAll, nil
+	default:
+		return nil, fmt.Errorf("unexpected robots.txt status code: %v", resp.StatusCode)
+	}
+}
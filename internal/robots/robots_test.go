@@ -0,0 +1,97 @@
+package robots
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newServer returns a server that responds to /robots.txt with status and body, and
+// counts how many times it was requested.
+func newServer(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	requests := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		requests.Add(1)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestCacheGet(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected bool
+	}{
+		{"2xx uses the rules", http.StatusOK, "User-agent: nomad\nDisallow: /", false},
+		{"2xx without a group allows", http.StatusOK, "User-agent: other\nDisallow: /", true},
+		{"4xx allows", http.StatusNotFound, "User-agent: *\nDisallow: /", true},
+		{"5xx disallows", http.StatusInternalServerError, "", false},
+		{"503 disallows", http.StatusServiceUnavailable, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, _ := newServer(t, test.status, test.body)
+			cache := NewCache(server.Client(), "nomad")
+
+			rules, err := cache.Get(context.Background(), server.URL+"/some/page", http.Header{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allowed := rules.Allowed("/"); allowed != test.expected {
+				t.Errorf("Allowed(\"/\") = %v, expected %v", allowed, test.expected)
+			}
+		})
+	}
+}
+
+func TestCacheGetCaches(t *testing.T) {
+	server, requests := newServer(t, http.StatusOK, "User-agent: *\nCrawl-delay: 2")
+	cache := NewCache(server.Client(), "nomad")
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(context.Background(), server.URL, http.Header{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("robots.txt was requested %d times, expected 1", n)
+	}
+}
+
+func TestCacheGetSendsHeader(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	header := http.Header{}
+	header.Set("User-Agent", "test-agent")
+	if _, err := NewCache(server.Client(), "nomad").Get(context.Background(), server.URL, header); err != nil {
+		t.Fatal(err)
+	}
+	if userAgent != "test-agent" {
+		t.Errorf("User-Agent = %q, expected %q", userAgent, "test-agent")
+	}
+}
+
+func TestCacheGetUnreachable(t *testing.T) {
+	server, _ := newServer(t, http.StatusOK, "")
+	server.Close()
+
+	if _, err := NewCache(server.Client(), "nomad").Get(context.Background(), server.URL, http.Header{}); err == nil {
+		t.Error("expected an error for an unreachable host")
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"github.com/psidex/nomad/internal/nomad"
 )
 
+// SessionConfig is sent by the client to start a session. Everything in nomad.Config,
+// such as respectRobotsTxt, can be set at the top level of the JSON.
 type SessionConfig struct {
 	nomad.Config
 	Runtime           lib.Duration `json:"runtime"`
 	HttpClientTimeout lib.Duration `json:"httpClientTimeout"`
+	// CollapseDirection counts links in either direction between two hostnames as one
+	// undirected edge, it's true unless the client sets it.
+	CollapseDirection bool `json:"collapseDirection"`
 }